@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AdminTokensEnvKeyName     = "ADMIN_TOKENS"
+	AdminHMACSecretEnvKeyName = "ADMIN_HMAC_SECRET"
+
+	// Signed requests older than this are rejected to stop replays
+	hmacMaxSkew = 5 * time.Minute
+)
+
+type actorKey struct{}
+
+// adminAuth holds the credentials accepted by the admin endpoints
+type adminAuth struct {
+	// tokens maps a bearer token to the name recorded in the audit trail
+	tokens     map[string]string
+	hmacSecret []byte
+}
+
+// loadAdminAuth reads ADMIN_TOKENS as name:token pairs separated by commas
+// and ADMIN_HMAC_SECRET for signed requests
+func loadAdminAuth() adminAuth {
+	auth := adminAuth{tokens: map[string]string{}}
+
+	for _, pair := range strings.Split(os.Getenv(AdminTokensEnvKeyName), ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && name != "" && token != "" {
+			auth.tokens[token] = name
+		}
+	}
+
+	if secret := os.Getenv(AdminHMACSecretEnvKeyName); secret != "" {
+		auth.hmacSecret = []byte(secret)
+	}
+
+	return auth
+}
+
+func (a adminAuth) configured() bool {
+	return len(a.tokens) > 0 || len(a.hmacSecret) > 0
+}
+
+var (
+	// Signatures already accepted, by the time they expire, so each signed
+	// request is accepted once
+	usedSignatures   = map[string]time.Time{}
+	usedSignaturesMu sync.Mutex
+)
+
+// useSignature reports whether a signature made at signed has not been
+// accepted before, remembering it until it is too old to be accepted anyway
+func useSignature(signature string, signed time.Time) bool {
+	usedSignaturesMu.Lock()
+	defer usedSignaturesMu.Unlock()
+
+	now := time.Now()
+	for used, expires := range usedSignatures {
+		if now.After(expires) {
+			delete(usedSignatures, used)
+		}
+	}
+
+	if _, ok := usedSignatures[signature]; ok {
+		return false
+	}
+	usedSignatures[signature] = signed.Add(hmacMaxSkew)
+	return true
+}
+
+// signaturePayload is what a signed request's HMAC covers. The method and
+// path, including any query, stop a signature being replayed to another
+// endpoint.
+func signaturePayload(r *http.Request, timestamp string, body []byte) []byte {
+	return append([]byte(r.Method+"\n"+r.URL.RequestURI()+"\n"+timestamp+"\n"), body...)
+}
+
+// authenticate returns the actor for a request. Bearer tokens name the actor
+// themselves. Signed requests send X-Admin-User, X-Timestamp in unix seconds
+// and X-Signature as hex HMAC-SHA256 of "method\npath\ntimestamp\nbody". Each
+// signature is accepted once.
+func (a adminAuth) authenticate(r *http.Request, body []byte) (string, bool) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for known, name := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(known)) == 1 {
+				return name, true
+			}
+		}
+		return "", false
+	}
+
+	signature := r.Header.Get("X-Signature")
+	if len(a.hmacSecret) == 0 || signature == "" {
+		return "", false
+	}
+
+	timestamp := r.Header.Get("X-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); skew > hmacMaxSkew || skew < -hmacMaxSkew {
+		return "", false
+	}
+
+	mac := hmac.New(sha256.New, a.hmacSecret)
+	mac.Write(signaturePayload(r, timestamp, body))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected)) {
+		return "", false
+	}
+	if !useSignature(expected, time.Unix(seconds, 0)) {
+		log.Printf("Admin signature replayed %s %s", r.Method, r.URL.Path)
+		return "", false
+	}
+
+	user := r.Header.Get("X-Admin-User")
+	if user == "" {
+		user = "hmac"
+	}
+	return user, true
+}
+
+// requireAdmin restricts a handler to authenticated requests using method.
+// The actor is stored in the request context for the audit trail.
+func requireAdmin(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed, use "+method)
+			return
+		}
+
+		if !adminCredentials.configured() {
+			writeJSONError(w, http.StatusForbidden, "admin authentication is not configured")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "error reading request body")
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		actor, ok := adminCredentials.authenticate(r, body)
+		if !ok {
+			log.Printf("Admin auth failed %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+	}
+}
+
+func actorFrom(r *http.Request) string {
+	actor, _ := r.Context().Value(actorKey{}).(string)
+	return actor
+}
+
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, jsonError{Error: msg})
+}
+
+// auditEntry is one line of the audit trail
+type auditEntry struct {
+	Time   time.Time   `json:"time"`
+	Actor  string      `json:"actor"`
+	Remote string      `json:"remote"`
+	Action string      `json:"action"`
+	Ticker string      `json:"ticker,omitempty"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+var auditMu sync.Mutex
+
+// audit appends an entry to the audit trail as a JSON line. An empty
+// auditPath, as in dry run, only logs the change.
+func audit(r *http.Request, action, ticker string, before, after interface{}) {
+	entry := auditEntry{
+		Time:   time.Now().UTC(),
+		Actor:  actorFrom(r),
+		Remote: r.RemoteAddr,
+		Action: action,
+		Ticker: ticker,
+		Before: before,
+		After:  after,
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("Audit encode error:", err)
+		return
+	}
+
+	if auditPath == "" {
+		log.Println("Audit:", string(line))
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.OpenFile(auditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Println("Audit write error:", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Println("Audit write error:", err)
+	}
+}
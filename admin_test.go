@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(secret, body string, at time.Time) *http.Request {
+	return signedFor(http.MethodPost, "/override", secret, body, at)
+}
+
+// signedFor signs a request for method and path
+func signedFor(method, path, secret, body string, at time.Time) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n" + body))
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("X-Timestamp", timestamp)
+	r.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	r.Header.Set("X-Admin-User", "ops")
+	return r
+}
+
+// replayed sends a signed request's headers and body to another method and path
+func replayed(signed *http.Request, method, path, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for _, header := range []string{"X-Timestamp", "X-Signature", "X-Admin-User"} {
+		r.Header.Set(header, signed.Header.Get(header))
+	}
+	return r
+}
+
+func TestRequireAdmin_Replay(t *testing.T) {
+	adminCredentials = adminAuth{hmacSecret: []byte("shared")}
+	defer func() { adminCredentials = adminAuth{} }()
+
+	override := `{"Ticker": "IBIT", "Result": {"TotalAsset": 1000}}`
+	tests := []struct {
+		name       string
+		method     string
+		request    *http.Request
+		wantStatus int
+	}{
+		{name: "different path", method: http.MethodPost,
+			request:    replayed(signedFor(http.MethodPost, "/override", "shared", override, time.Now()), http.MethodPost, "/update", override),
+			wantStatus: http.StatusUnauthorized},
+		{name: "different method", method: http.MethodPost,
+			request:    replayed(signedFor(http.MethodGet, "/staleness", "shared", "", time.Now()), http.MethodPost, "/staleness", ""),
+			wantStatus: http.StatusUnauthorized},
+		{name: "different query", method: http.MethodGet,
+			request:    replayed(signedFor(http.MethodGet, "/revisions?ticker=IBIT", "shared", "", time.Now()), http.MethodGet, "/revisions?ticker=FBTC", ""),
+			wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			requireAdmin(tt.method, func(w http.ResponseWriter, r *http.Request) {})(w, tt.request)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	// A signature is accepted once
+	signed := signedFor(http.MethodPost, "/reload", "shared", "", time.Now())
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		requireAdmin(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {})(w, replayed(signed, http.MethodPost, "/reload", ""))
+		if w.Code != want {
+			t.Errorf("request %d status = %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	adminCredentials = adminAuth{tokens: map[string]string{"secret-token": "alice"}, hmacSecret: []byte("shared")}
+	defer func() { adminCredentials = adminAuth{} }()
+
+	body := `{"Ticker": "IBIT"}`
+	bearer := func(method, token string) *http.Request {
+		r := httptest.NewRequest(method, "/override", strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		request    *http.Request
+		wantStatus int
+		wantActor  string
+	}{
+		{name: "bearer", request: bearer(http.MethodPost, "secret-token"), wantStatus: http.StatusOK, wantActor: "alice"},
+		{name: "wrong token", request: bearer(http.MethodPost, "guess"), wantStatus: http.StatusUnauthorized},
+		{name: "no credentials", request: bearer(http.MethodPost, ""), wantStatus: http.StatusUnauthorized},
+		{name: "GET", request: bearer(http.MethodGet, "secret-token"), wantStatus: http.StatusMethodNotAllowed},
+		{name: "hmac", request: signedRequest("shared", body, time.Now()), wantStatus: http.StatusOK, wantActor: "ops"},
+		{name: "hmac wrong secret", request: signedRequest("other", body, time.Now()), wantStatus: http.StatusUnauthorized},
+		{name: "hmac replay", request: signedRequest("shared", body, time.Now().Add(-time.Hour)), wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotActor string
+			handler := requireAdmin(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+				gotActor = actorFrom(r)
+			})
+
+			w := httptest.NewRecorder()
+			handler(w, tt.request)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if gotActor != tt.wantActor {
+				t.Errorf("actor = %q, want %q", gotActor, tt.wantActor)
+			}
+			if w.Code != http.StatusOK && w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("error Content-Type = %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandleData_Validation(t *testing.T) {
+	auditPath = filepath.Join(t.TempDir(), "audit.log")
+	today := time.Now().UTC().Format("2006-01-02")
+
+	tests := []struct {
+		name       string
+		updateType string
+		body       string
+		wantStatus int
+	}{
+		{name: "valid override", updateType: "override", body: `{"Ticker": "IBIT", "Result": {"TotalAsset": 1000, "Date": "` + today + `T00:00:00Z"}}`, wantStatus: http.StatusOK},
+		{name: "unknown ticker", updateType: "override", body: `{"Ticker": "XXXX", "Result": {"TotalAsset": 1000}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "negative total", updateType: "update", body: `{"Ticker": "IBIT", "Result": {"TotalAsset": -1}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "zero override", updateType: "override", body: `{"Ticker": "IBIT", "Result": {"TotalAsset": 0}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "old date", updateType: "update", body: `{"Ticker": "IBIT", "Result": {"TotalAsset": 1, "Date": "2020-01-02T00:00:00Z"}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "bad JSON", updateType: "update", body: `{"Ticker": `, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", updateType: "update", body: `{"Tikcer": "IBIT"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleData(w, httptest.NewRequest(http.MethodPost, "/"+tt.updateType, strings.NewReader(tt.body)), tt.updateType)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
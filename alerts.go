@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/calendar"
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/notifier"
+	"github.com/jyap808/btcEtfScrape/rules"
+)
+
+// newRulesEngine loads the rules file and checks every rule routes to a known channel
+func newRulesEngine(path string) (*rules.Engine, error) {
+	if path == "" {
+		return rules.NewEngine(nil), nil
+	}
+
+	loaded, err := rules.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRuleChannels(loaded); err != nil {
+		return nil, err
+	}
+
+	engine := rules.NewEngine(loaded)
+	engine.Watch(path)
+
+	return engine, nil
+}
+
+func checkRuleChannels(loaded []rules.Rule) error {
+	known := map[string]bool{string(message.Discord): true}
+	for _, channel := range socialChannels() {
+		known[channel] = true
+	}
+
+	for _, rule := range loaded {
+		for _, channel := range rule.Channels {
+			if !known[channel] {
+				return fmt.Errorf("rule %s: unknown channel %q", rule.Name, channel)
+			}
+		}
+	}
+
+	return nil
+}
+
+// evaluateRules runs a flow through the rules and sends any alerts
+func evaluateRules(event message.Event) {
+	for _, match := range rulesEngine.Evaluate(event, time.Now()) {
+		notifyAlert(match)
+	}
+}
+
+// runRules reloads changed rules and checks for missing updates every minute
+func runRules() {
+	for {
+		time.Sleep(time.Minute)
+
+		previous := rulesEngine.Rules()
+		reloaded, err := rulesEngine.Reload()
+		if err != nil {
+			log.Println("Rules reload error:", err)
+		} else if reloaded {
+			if err := checkRuleChannels(rulesEngine.Rules()); err != nil {
+				log.Println("Rules reload error:", err)
+				rulesEngine.SetRules(previous)
+			} else {
+				log.Printf("Rules reloaded: %d rules", len(rulesEngine.Rules()))
+			}
+		}
+
+		// Funds can be enabled or disabled by a reload
+		tickers := make([]string, 0, len(details()))
+		for ticker := range details() {
+			tickers = append(tickers, ticker)
+		}
+		for _, match := range rulesEngine.CheckMissing(time.Now(), tickers) {
+			notifyAlert(match)
+		}
+		checkStaleness(time.Now())
+	}
+}
+
+// notifyOps sends an operational alert, such as a broken source, to the ops
+// channels. The same alert is sent once a day, as dry run and channels without
+// the outbox have no other dedup.
+func notifyOps(rule string, event message.Event, reason string) {
+	day := calendar.Day(time.Now()).Format("2006-01-02")
+	if !alertOnce("ops:"+rule, event.Ticker, day+" "+reason) {
+		log.Printf("Alert %s %s already sent today: %s", rule, event.Ticker, reason)
+		return
+	}
+
+	notifyAlert(rules.Match{
+		Rule:   rules.Rule{Name: rule, Channels: conf().OpsChannels},
+		Event:  event,
+		Reason: reason,
+	})
+}
+
+// notifyAlert sends a matched rule to each of its channels
+func notifyAlert(match rules.Match) {
+	event := match.Event
+	event.Description = details()[event.Ticker].Description
+	event.Rule = match.Rule.Name
+	event.Alert = match.Reason
+
+	log.Printf("Alert %s %s: %s", event.Rule, event.Ticker, event.Alert)
+
+	for _, channel := range match.Rule.Channels {
+		// Discord has its own template, the other channels share the X one
+		templateChannel := message.X
+		if channel == string(message.Discord) {
+			templateChannel = message.Discord
+		}
+
+		text, err := templates().Render(templateChannel, message.Alert, event)
+		if err != nil {
+			log.Printf("Render %s %s error: %v", templateChannel, message.Alert, err)
+			continue
+		}
+		if text == "" {
+			continue
+		}
+
+		msg := notifier.Message{Text: text}
+		if templateChannel == message.Discord {
+			msg.Embed = discordEmbed(message.Alert, event)
+		}
+		deliver(channel, message.Alert, event, msg, false)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/revision"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+// Largest accepted batch request body
+const maxBatchBytes = 1 << 20
+
+// batchRow is one validated row of a batch and the flow it would produce
+type batchRow struct {
+	Row     int          `json:"row"`
+	Ticker  string       `json:"ticker"`
+	Result  types.Result `json:"result"`
+	Preview flowPreview  `json:"preview"`
+}
+
+// batchError reports why a row was rejected
+type batchError struct {
+	Row    int    `json:"row"`
+	Ticker string `json:"ticker,omitempty"`
+	Error  string `json:"error"`
+}
+
+// parseBatch reads manual rows from a JSON array, a CSV body or a CSV file
+// uploaded as the "file" form field
+func parseBatch(r *http.Request) ([]manualData, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return parseBatchCSV(r.Body)
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("reading upload: %w", err)
+		}
+		defer file.Close()
+		return parseBatchCSV(file)
+	}
+
+	var rows []manualData
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return rows, nil
+}
+
+// parseBatchCSV reads ticker,total,date rows with an optional header line.
+// The date is YYYY-MM-DD and may be empty.
+func parseBatchCSV(r io.Reader) ([]manualData, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "ticker") {
+		records = records[1:]
+	}
+
+	var rows []manualData
+	for i, record := range records {
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("CSV line %d: want ticker,total[,date]", i+1)
+		}
+		total, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(record[1]), ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d: invalid total %q", i+1, record[1])
+		}
+		data := manualData{
+			Ticker: strings.ToUpper(strings.TrimSpace(record[0])),
+			Result: types.Result{TotalAsset: total},
+		}
+		if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+			date, err := time.Parse("2006-01-02", strings.TrimSpace(record[2]))
+			if err != nil {
+				return nil, fmt.Errorf("CSV line %d: invalid date %q", i+1, record[2])
+			}
+			data.Result.Date = date
+		}
+		rows = append(rows, data)
+	}
+	return rows, nil
+}
+
+// validateBatch checks every row and rejects duplicate tickers, returning all
+// problems so the whole batch can be fixed at once
+func validateBatch(rows []manualData, updateType string) []batchError {
+	var problems []batchError
+	seen := map[string]int{}
+	for i, data := range rows {
+		row := i + 1
+		if err := validateManualData(data, updateType); err != nil {
+			problems = append(problems, batchError{Row: row, Ticker: data.Ticker, Error: err.Error()})
+			continue
+		}
+		if first, ok := seen[data.Ticker]; ok {
+			problems = append(problems, batchError{Row: row, Ticker: data.Ticker, Error: fmt.Sprintf("duplicate of row %d", first)})
+			continue
+		}
+		seen[data.Ticker] = row
+	}
+	return problems
+}
+
+// handleBatch validates a batch of overrides or updates and applies them as
+// one change set. Nothing is applied if any row is invalid. With preview=true
+// the flows are returned without applying.
+func handleBatch(w http.ResponseWriter, r *http.Request) {
+	updateType := r.URL.Query().Get("type")
+	if updateType != "override" && updateType != "update" {
+		writeJSONError(w, http.StatusUnprocessableEntity, `type must be "override" or "update"`)
+		return
+	}
+	previewOnly, _ := strconv.ParseBool(r.URL.Query().Get("preview"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBytes)
+	rows, err := parseBatch(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		writeJSONError(w, http.StatusUnprocessableEntity, "batch is empty")
+		return
+	}
+	if problems := validateBatch(rows, updateType); len(problems) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error": fmt.Sprintf("%d of %d rows rejected, nothing applied", len(problems), len(rows)),
+			"rows":  problems,
+		})
+		return
+	}
+
+	// Previews price each row, so build them before taking the lock
+	var (
+		results  []batchRow
+		assetSum float64
+		flowSum  float64
+	)
+	for i, data := range rows {
+		preview, err := previewManualData(data, updateType)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("row %d: %v", i+1, err))
+			return
+		}
+		results = append(results, batchRow{
+			Row:     i + 1,
+			Ticker:  data.Ticker,
+			Result:  types.Result{TotalAsset: data.Result.TotalAsset, Date: data.Result.Date},
+			Preview: preview,
+		})
+		assetSum += preview.Event.AssetDiff
+		flowSum += preview.Event.FlowDiff
+	}
+
+	status := "preview"
+	if !previewOnly {
+		befores := make([]types.Result, len(results))
+
+		resultsMu.Lock()
+		for i, row := range results {
+			switch updateType {
+			case "override":
+				befores[i] = tickerResultsOverride[row.Ticker]
+				tickerResultsOverride[row.Ticker] = row.Result
+			case "update":
+				befores[i] = tickerResults[row.Ticker]
+				tickerResults[row.Ticker] = row.Result
+			}
+		}
+		resultsMu.Unlock()
+
+		for i, row := range results {
+			audit(r, "batch "+updateType, row.Ticker, befores[i], row.Result)
+			if updateType == "update" {
+				recordRevision(row.Ticker, revision.Update, actorFrom(r), befores[i], row.Result, nil)
+			}
+		}
+		log.Printf("Batch %s of %d rows by %s", updateType, len(results), actorFrom(r))
+		status = "batch " + updateType + " successful"
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         status,
+		"rows":           results,
+		"totalAssetDiff": assetSum,
+		"totalFlowDiff":  flowSum,
+	})
+}
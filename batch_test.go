@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestParseBatchCSV(t *testing.T) {
+	rows, err := parseBatchCSV(strings.NewReader("ticker,total,date\nfbtc, \"1,000.5\",2024-03-01\nARKB,2000\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if rows[0].Ticker != "FBTC" || rows[0].Result.TotalAsset != 1000.5 || rows[0].Result.Date.Format("2006-01-02") != "2024-03-01" {
+		t.Errorf("row 1 = %+v", rows[0])
+	}
+	if rows[1].Ticker != "ARKB" || rows[1].Result.TotalAsset != 2000 || !rows[1].Result.Date.IsZero() {
+		t.Errorf("row 2 = %+v", rows[1])
+	}
+
+	for _, bad := range []string{"FBTC\n", "FBTC,lots\n", "FBTC,1,yesterday\n"} {
+		if _, err := parseBatchCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseBatchCSV(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestHandleBatch(t *testing.T) {
+	auditPath = filepath.Join(t.TempDir(), "audit.log")
+	today := time.Now().UTC().Format("2006-01-02")
+	defer func() {
+		for _, ticker := range []string{"FBTC", "ARKB"} {
+			setResult(ticker, types.Result{})
+		}
+	}()
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		wantStatus  int
+		wantApplied bool
+	}{
+		{name: "bad type", query: "type=delete", body: `[]`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "empty", query: "type=update", body: `[]`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "one invalid row", query: "type=update", body: `[{"Ticker": "FBTC", "Result": {"TotalAsset": 1000}}, {"Ticker": "XXXX", "Result": {"TotalAsset": 1}}]`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "duplicate ticker", query: "type=update", contentType: "text/csv", body: "FBTC,1000\nFBTC,1001\n", wantStatus: http.StatusUnprocessableEntity},
+		{name: "bad CSV", query: "type=update", contentType: "text/csv", body: "FBTC\n", wantStatus: http.StatusBadRequest},
+		{name: "preview", query: "type=update&preview=true", contentType: "text/csv", body: "FBTC,1000," + today + "\nARKB,2000\n", wantStatus: http.StatusOK},
+		{name: "apply", query: "type=update", contentType: "text/csv", body: "FBTC,1000," + today + "\nARKB,2000\n", wantStatus: http.StatusOK, wantApplied: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/batch?"+tt.query, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			handleBatch(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			applied := currentResult("FBTC").TotalAsset == 1000 && currentResult("ARKB").TotalAsset == 2000
+			if applied != tt.wantApplied {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Package calendar knows the US equity trading days funds publish holdings for.
+
+Trading days are weekdays other than the NYSE full day holidays. Functions
+taking a date use its calendar date, as issuers publish them; use Day to turn
+an instant into its New York date first.
+*/
+package calendar
+
+import (
+	"time"
+)
+
+// Close is the hour in New York time the market closes
+const Close = 16
+
+var location = loadLocation()
+
+func loadLocation() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// Location is New York, where the trading calendar applies
+func Location() *time.Location {
+	return location
+}
+
+// Day returns midnight in New York of the day t falls on there
+func Day(t time.Time) time.Time {
+	local := t.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+}
+
+// date returns midnight in New York of a calendar date, ignoring the zone it
+// is given in. Issuer dates are parsed as midnight UTC.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+// IsTradingDay reports whether the calendar date of t is a trading day
+func IsTradingDay(t time.Time) bool {
+	day := date(t)
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	return !IsHoliday(day)
+}
+
+// Previous returns the last trading day before the calendar date of t
+func Previous(t time.Time) time.Time {
+	day := date(t).AddDate(0, 0, -1)
+	for !IsTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// Latest returns the calendar date of t when it is a trading day, otherwise
+// the trading day before it
+func Latest(t time.Time) time.Time {
+	day := date(t)
+	if IsTradingDay(day) {
+		return day
+	}
+	return Previous(day)
+}
+
+// ClosingTime returns the market close on the calendar date of t
+func ClosingTime(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), Close, 0, 0, 0, location)
+}
+
+// Between counts the trading days after from up to and including to, by
+// calendar date. It is zero when to is not after from.
+func Between(from, to time.Time) int {
+	count := 0
+	for day := date(from).AddDate(0, 0, 1); !day.After(date(to)); day = day.AddDate(0, 0, 1) {
+		if IsTradingDay(day) {
+			count++
+		}
+	}
+	return count
+}
+
+// IsHoliday reports whether the calendar date of t is an NYSE full day holiday
+func IsHoliday(t time.Time) bool {
+	day := date(t)
+	for _, holiday := range holidays(day.Year()) {
+		if holiday.Equal(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// holidays returns the observed NYSE full day holidays of a year
+func holidays(year int) []time.Time {
+	on := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, location)
+	}
+
+	days := []time.Time{
+		// New Year's Day on a Saturday is not observed on the Friday before
+		observed(on(time.January, 1), false),
+		nth(year, time.January, time.Monday, 3),  // Martin Luther King Jr. Day
+		nth(year, time.February, time.Monday, 3), // Washington's Birthday
+		easter(year).AddDate(0, 0, -2),           // Good Friday
+		last(year, time.May, time.Monday),        // Memorial Day
+		observed(on(time.July, 4), true),
+		nth(year, time.September, time.Monday, 1),  // Labor Day
+		nth(year, time.November, time.Thursday, 4), // Thanksgiving
+		observed(on(time.December, 25), true),
+	}
+	if year >= 2022 {
+		days = append(days, observed(on(time.June, 19), true))
+	}
+	return days
+}
+
+// observed moves a holiday on a Sunday to the Monday after, and one on a
+// Saturday to the Friday before when saturdayToFriday is set
+func observed(day time.Time, saturdayToFriday bool) time.Time {
+	switch day.Weekday() {
+	case time.Sunday:
+		return day.AddDate(0, 0, 1)
+	case time.Saturday:
+		if saturdayToFriday {
+			return day.AddDate(0, 0, -1)
+		}
+	}
+	return day
+}
+
+// nth returns the nth weekday of a month
+func nth(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	day := time.Date(year, month, 1, 0, 0, 0, 0, location)
+	for day.Weekday() != weekday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.AddDate(0, 0, 7*(n-1))
+}
+
+// last returns the last weekday of a month
+func last(year int, month time.Month, weekday time.Weekday) time.Time {
+	day := time.Date(year, month+1, 0, 0, 0, 0, 0, location)
+	for day.Weekday() != weekday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// easter returns Easter Sunday using the anonymous Gregorian algorithm
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, location)
+}
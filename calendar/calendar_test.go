@@ -0,0 +1,83 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestIsTradingDay(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want bool
+	}{
+		{date: day(2024, 3, 1), want: true},
+		{date: day(2024, 3, 2), want: false},                                                 // Saturday
+		{date: day(2024, 1, 1), want: false},                                                 // New Year's Day
+		{date: day(2024, 1, 15), want: false},                                                // Martin Luther King Jr. Day
+		{date: day(2024, 2, 19), want: false},                                                // Washington's Birthday
+		{date: day(2024, 3, 29), want: false},                                                // Good Friday
+		{date: day(2024, 5, 27), want: false},                                                // Memorial Day
+		{date: day(2024, 6, 19), want: false},                                                // Juneteenth
+		{date: day(2024, 7, 4), want: false},                                                 // Independence Day
+		{date: day(2024, 9, 2), want: false},                                                 // Labor Day
+		{date: day(2024, 11, 28), want: false},                                               // Thanksgiving
+		{date: day(2024, 12, 25), want: false},                                               // Christmas
+		{date: day(2021, 12, 31), want: true},                                                // New Year's Day 2022 on a Saturday is not observed
+		{date: day(2021, 12, 24), want: false},                                               // Christmas 2021 on a Saturday, observed Friday
+		{date: day(2023, 1, 2), want: false},                                                 // New Year's Day 2023 on a Sunday, observed Monday
+		{date: day(2026, 7, 3), want: false},                                                 // Independence Day 2026 on a Saturday, observed Friday
+		{date: day(2025, 4, 18), want: false},                                                // Good Friday 2025
+		{date: day(2021, 6, 18), want: true},                                                 // before Juneteenth was a market holiday
+		{date: time.Date(2024, 3, 1, 23, 0, 0, 0, time.FixedZone("", -5*60*60)), want: true}, // calendar date, not the instant
+	}
+	for _, tt := range tests {
+		if got := IsTradingDay(tt.date); got != tt.want {
+			t.Errorf("IsTradingDay(%s) = %v, want %v", tt.date.Format("2006-01-02 Mon"), got, tt.want)
+		}
+	}
+}
+
+func TestPreviousAndLatest(t *testing.T) {
+	// Good Friday and the weekend before Monday 2024-04-01
+	if got := Previous(day(2024, 4, 1)); got.Format("2006-01-02") != "2024-03-28" {
+		t.Errorf("Previous() = %s, want 2024-03-28", got.Format("2006-01-02"))
+	}
+	if got := Latest(day(2024, 3, 31)); got.Format("2006-01-02") != "2024-03-28" {
+		t.Errorf("Latest() = %s, want 2024-03-28", got.Format("2006-01-02"))
+	}
+	if got := Latest(day(2024, 4, 1)); got.Format("2006-01-02") != "2024-04-01" {
+		t.Errorf("Latest() = %s, want 2024-04-01", got.Format("2006-01-02"))
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		from, to time.Time
+		want     int
+	}{
+		{from: day(2024, 3, 1), to: day(2024, 3, 1), want: 0},
+		{from: day(2024, 3, 1), to: day(2024, 3, 4), want: 1},
+		{from: day(2024, 3, 27), to: day(2024, 4, 2), want: 3}, // skips Good Friday and the weekend
+		{from: day(2024, 3, 4), to: day(2024, 3, 1), want: 0},
+	}
+	for _, tt := range tests {
+		if got := Between(tt.from, tt.to); got != tt.want {
+			t.Errorf("Between(%s, %s) = %d, want %d", tt.from.Format("2006-01-02"), tt.to.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestDay(t *testing.T) {
+	// 03:00 UTC is still the previous evening in New York
+	got := Day(time.Date(2024, 3, 5, 3, 0, 0, 0, time.UTC))
+	if got.Format("2006-01-02") != "2024-03-04" {
+		t.Errorf("Day() = %s, want 2024-03-04", got.Format("2006-01-02"))
+	}
+	if close := ClosingTime(got); close.Hour() != 16 || close.Location() != Location() {
+		t.Errorf("ClosingTime() = %v", close)
+	}
+}
@@ -0,0 +1,196 @@
+package cmebrrny
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ReferenceRatesURL returns every CME CF benchmark with its trailing fixings
+var ReferenceRatesURL = "https://www.cmegroup.com/services/cryptocurrencies/reference-rates"
+
+// Benchmark describes a CME CF series and when it is published
+type Benchmark struct {
+	ID    string
+	Name  string
+	Asset string
+	// Location and Hour give the local time of the daily fixing
+	Location string
+	Hour     int
+}
+
+// Known benchmark IDs as keyed in the reference-rates response
+const (
+	BRR       = "BRR"
+	BRRNY     = "BRRNY"
+	BRRAP     = "BRRAP"
+	ETHUSD_RR = "ETHUSD_RR"
+	ETHUSD_NY = "ETHUSD_NY_RR"
+	ETHUSD_AP = "ETHUSD_AP_RR"
+)
+
+const (
+	londonZone = "Europe/London"
+	nyZone     = "America/New_York"
+	hkZone     = "Asia/Hong_Kong"
+)
+
+var Benchmarks = map[string]Benchmark{
+	BRR:       {ID: BRR, Name: "CME CF Bitcoin Reference Rate", Asset: "BTC", Location: londonZone, Hour: 16},
+	BRRNY:     {ID: BRRNY, Name: "CME CF Bitcoin Reference Rate - New York Variant", Asset: "BTC", Location: nyZone, Hour: 16},
+	BRRAP:     {ID: BRRAP, Name: "CME CF Bitcoin Reference Rate - Asia Pacific Variant", Asset: "BTC", Location: hkZone, Hour: 16},
+	ETHUSD_RR: {ID: ETHUSD_RR, Name: "CME CF Ether-Dollar Reference Rate", Asset: "ETH", Location: londonZone, Hour: 16},
+	ETHUSD_NY: {ID: ETHUSD_NY, Name: "CME CF Ether-Dollar Reference Rate - New York Variant", Asset: "ETH", Location: nyZone, Hour: 16},
+	ETHUSD_AP: {ID: ETHUSD_AP, Name: "CME CF Ether-Dollar Reference Rate - Asia Pacific Variant", Asset: "ETH", Location: hkZone, Hour: 16},
+}
+
+// Publication returns the fixing time on the calendar day of day
+func (b Benchmark) Publication(day time.Time) (time.Time, error) {
+	location, err := time.LoadLocation(b.Location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := day.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), b.Hour, 0, 0, 0, location), nil
+}
+
+// NextPublication returns the first fixing time after t
+func (b Benchmark) NextPublication(t time.Time) (time.Time, error) {
+	next, err := b.Publication(t)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// Series is one benchmark's fixings, newest first
+type Series struct {
+	Benchmark Benchmark
+	Rates     []ReferenceRate
+}
+
+// Latest returns the newest fixing
+func (s Series) Latest() (ReferenceRate, bool) {
+	if len(s.Rates) == 0 {
+		return ReferenceRate{}, false
+	}
+	return s.Rates[0], true
+}
+
+// Rates is every series in a reference-rates response
+type Rates struct {
+	Series  map[string]Series
+	Fetched time.Time
+}
+
+// IDs lists the series in the response, sorted
+func (r Rates) IDs() []string {
+	ids := make([]string, 0, len(r.Series))
+	for id := range r.Series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Get returns the fixings for a series ID, newest first
+func (r Rates) Get(id string) []ReferenceRate {
+	return r.Series[id].Rates
+}
+
+func (r Rates) BRR() []ReferenceRate        { return r.Get(BRR) }
+func (r Rates) BRRNY() []ReferenceRate      { return r.Get(BRRNY) }
+func (r Rates) BRRAP() []ReferenceRate      { return r.Get(BRRAP) }
+func (r Rates) ETHUSDRR() []ReferenceRate   { return r.Get(ETHUSD_RR) }
+func (r Rates) ETHUSDNYRR() []ReferenceRate { return r.Get(ETHUSD_NY) }
+func (r Rates) ETHUSDAPRR() []ReferenceRate { return r.Get(ETHUSD_AP) }
+
+// ParseReferenceRates decodes a reference-rates response. Series that are not
+// a list of fixings are skipped so one odd entry does not hide the rest.
+func ParseReferenceRates(body []byte) (Rates, error) {
+	var data map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(body, &data); err != nil {
+		return Rates{}, err
+	}
+
+	raw, ok := data["referenceRates"]
+	if !ok {
+		return Rates{}, fmt.Errorf("referenceRates not found in response")
+	}
+
+	rates := Rates{Series: map[string]Series{}}
+	for id, message := range raw {
+		var fixings []ReferenceRate
+		if err := json.Unmarshal(message, &fixings); err != nil {
+			log.Printf("Skipping reference rate series %s: %v", id, err)
+			continue
+		}
+
+		// Newest first regardless of the order in the response
+		sort.SliceStable(fixings, func(i, j int) bool {
+			return fixings[i].Date.After(fixings[j].Date)
+		})
+
+		benchmark, ok := Benchmarks[id]
+		if !ok {
+			benchmark = Benchmark{ID: id, Name: id}
+		}
+		rates.Series[id] = Series{Benchmark: benchmark, Rates: fixings}
+	}
+
+	return rates, nil
+}
+
+// Return every CME CF benchmark in the reference-rates response
+func GetReferenceRates() (Rates, error) {
+	// Create a new HTTP client
+	client := http.Client{}
+
+	// Create a new GET request
+	req, err := http.NewRequest("GET", ReferenceRatesURL, nil)
+	if err != nil {
+		log.Println("Error creating request:", err)
+		return Rates{}, err
+	}
+
+	// Set headers
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15")
+
+	// Perform the request
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Println("Error performing request:", err)
+		return Rates{}, err
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Println("Error reading response body:", err)
+		return Rates{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Rates{}, fmt.Errorf("reference rates status %d", resp.StatusCode)
+	}
+
+	rates, err := ParseReferenceRates(body)
+	if err != nil {
+		log.Printf("Error unmarshalling JSON: %v", err)
+		return Rates{}, err
+	}
+	rates.Fetched = time.Now()
+
+	return rates, nil
+}
@@ -0,0 +1,106 @@
+package cmebrrny
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseReferenceRates(t *testing.T) {
+	body, err := os.ReadFile("testdata/reference-rates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rates, err := ParseReferenceRates(body)
+	if err != nil {
+		t.Fatalf("ParseReferenceRates() error = %v", err)
+	}
+
+	wantIDs := []string{"BRR", "BRRAP", "BRRNY", "ETHUSD_NY_RR", "ETHUSD_RR", "SOLUSD_RR"}
+	if got := rates.IDs(); !reflect.DeepEqual(got, wantIDs) {
+		t.Errorf("IDs() got = %v, want %v", got, wantIDs)
+	}
+
+	tests := []struct {
+		name  string
+		rates []ReferenceRate
+		want  ReferenceRate
+	}{
+		{name: "BRR", rates: rates.BRR(), want: ReferenceRate{Value: 52066.83, Date: time.Date(2024, 2, 16, 16, 0, 0, 0, time.UTC)}},
+		{name: "BRRNY", rates: rates.BRRNY(), want: ReferenceRate{Value: 51840.37, Date: time.Date(2024, 2, 16, 21, 0, 0, 0, time.UTC)}},
+		{name: "BRRAP", rates: rates.BRRAP(), want: ReferenceRate{Value: 51985.40, Date: time.Date(2024, 2, 16, 8, 0, 0, 0, time.UTC)}},
+		{name: "ETHUSD_RR", rates: rates.ETHUSDRR(), want: ReferenceRate{Value: 2810.21, Date: time.Date(2024, 2, 16, 16, 0, 0, 0, time.UTC)}},
+		{name: "ETHUSD_NY_RR", rates: rates.ETHUSDNYRR(), want: ReferenceRate{Value: 2799.66, Date: time.Date(2024, 2, 16, 21, 0, 0, 0, time.UTC)}},
+		{name: "unknown series", rates: rates.Get("SOLUSD_RR"), want: ReferenceRate{Value: 110.21, Date: time.Date(2024, 2, 16, 16, 0, 0, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.rates) != 5 {
+				t.Fatalf("got %d fixings, want 5", len(tt.rates))
+			}
+			if got := tt.rates[0]; got.Value != tt.want.Value || !got.Date.Equal(tt.want.Date) {
+				t.Errorf("latest got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := rates.ETHUSDAPRR(); got != nil {
+		t.Errorf("ETHUSDAPRR() missing series got = %v", got)
+	}
+	if b := rates.Series["SOLUSD_RR"].Benchmark; b.ID != "SOLUSD_RR" || b.Location != "" {
+		t.Errorf("unknown series Benchmark = %+v", b)
+	}
+}
+
+func TestBenchmark_NextPublication(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	london, _ := time.LoadLocation("Europe/London")
+
+	tests := []struct {
+		name      string
+		benchmark Benchmark
+		at        time.Time
+		want      time.Time
+	}{
+		{name: "BRRNY before fixing", benchmark: Benchmarks[BRRNY], at: time.Date(2024, 2, 16, 15, 0, 0, 0, newYork), want: time.Date(2024, 2, 16, 16, 0, 0, 0, newYork)},
+		{name: "BRRNY after fixing", benchmark: Benchmarks[BRRNY], at: time.Date(2024, 2, 16, 16, 0, 0, 0, newYork), want: time.Date(2024, 2, 17, 16, 0, 0, 0, newYork)},
+		{name: "BRR in London", benchmark: Benchmarks[BRR], at: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC), want: time.Date(2024, 7, 1, 16, 0, 0, 0, london)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.benchmark.NextPublication(tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextPublication() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBRRYNY(t *testing.T) {
+	body, err := os.ReadFile("testdata/reference-rates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	defer func(url string) { ReferenceRatesURL = url }(ReferenceRatesURL)
+	ReferenceRatesURL = server.URL
+
+	rr, err := GetBRRYNY()
+	if err != nil {
+		t.Fatalf("GetBRRYNY() error = %v", err)
+	}
+	if rr[0].Value != 51840.37 || rr[4].Value != 48307.86 {
+		t.Errorf("GetBRRYNY() got = %+v", rr)
+	}
+}
@@ -2,15 +2,15 @@
 The CME CF Bitcoin Reference Rate – New York Variant is a once a day (4pm ET)
 benchmark price for bitcoin, measured in US dollars per bitcoin.
 
+The same endpoint publishes the other CME CF benchmarks, such as the London
+BRR and the Ether reference rates, which are parsed alongside it.
+
 https://www.cmegroup.com/markets/cryptocurrencies/cme-cf-cryptocurrency-benchmarks.html
 */
 package cmebrrny
 
 import (
 	"encoding/json"
-	"io"
-	"log"
-	"net/http"
 	"time"
 )
 
@@ -19,10 +19,6 @@ type ReferenceRate struct {
 	Date  time.Time `json:"date"`
 }
 
-type ReferenceRates struct {
-	BRRNY [5]ReferenceRate `json:"BRRNY"`
-}
-
 // Custom unmarshalling function for time.Time field
 func (rr *ReferenceRate) UnmarshalJSON(data []byte) error {
 	var tmp struct {
@@ -43,44 +39,12 @@ func (rr *ReferenceRate) UnmarshalJSON(data []byte) error {
 
 // Return the CME BRR NY trailing 5 day prices
 func GetBRRYNY() (referenceRates [5]ReferenceRate, err error) {
-	url := "https://www.cmegroup.com/services/cryptocurrencies/reference-rates"
-
-	// Create a new HTTP client
-	client := http.Client{}
-
-	// Create a new GET request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Println("Error creating request:", err)
-		return [5]ReferenceRate{}, err
-	}
-
-	// Set headers
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15")
-
-	// Perform the request
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error performing request:", err)
-		return [5]ReferenceRate{}, err
-	}
-	defer resp.Body.Close()
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	rates, err := GetReferenceRates()
 	if err != nil {
-		log.Println("Error reading response body:", err)
 		return [5]ReferenceRate{}, err
 	}
 
-	// Parse JSON data into struct
-	var data map[string]ReferenceRates
-	if err := json.Unmarshal(body, &data); err != nil {
-		log.Printf("Error unmarshalling JSON: %v", err)
-		return [5]ReferenceRate{}, err
-	}
+	copy(referenceRates[:], rates.BRRNY())
 
-	return data["referenceRates"].BRRNY, nil
+	return referenceRates, nil
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/funds"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+// collectors are the sources of each fund's current holdings, the default
+// primary first
+var collectors = map[string][]funds.Source{
+	"ARKB": {{Name: "holdings-csv", Collect: funds.ArkbCollect}, {Name: "fund-page", Collect: funds.ArkbPageCollect}},
+	"BITB": {{Name: "website", Collect: funds.BitbCollect}},
+	"BRRR": {{Name: "holdings-page", Collect: funds.BrrrCollect}},
+	"BTCW": {{Name: "holdings-modal", Collect: funds.BtcwCollect}},
+	"DEFI": {{Name: "website", Collect: funds.DefiCollect}},
+	"EZBC": {{Name: "pds-api", Collect: funds.EzbcCollect}},
+	"FBTC": {{Name: "daily-pdf", Collect: funds.FbtcCollect}},
+	"GBTC": {{Name: "website", Collect: funds.GbtcCollect}},
+	"HODL": {{Name: "nav-block", Collect: funds.HodlCollect}},
+	"IBIT": {{Name: "ajax-json", Collect: funds.IbitCollect}, {Name: "holdings-csv", Collect: funds.IbitCSVCollect}},
+}
+
+// collectResult is one collector run
+type collectResult struct {
+	Ticker string       `json:"ticker"`
+	Result types.Result `json:"result"`
+	// Sources holds each source's result when the fund has more than one
+	Sources map[string]types.Result `json:"sources,omitempty"`
+	// Diagnostics of the sources that read HTML
+	Diagnostics map[string]*scrape.Diagnostics `json:"diagnostics,omitempty"`
+	Latency     string                         `json:"latency"`
+	Error       string                         `json:"error,omitempty"`
+}
+
+// collectOnce runs a fund's sources, turning a panic, a timeout, an empty
+// result or a disagreement between sources into an error
+func collectOnce(ticker string, combine func() funds.Outcome, timeout time.Duration) collectResult {
+	start := time.Now()
+	done := make(chan collectResult, 1)
+
+	go func() {
+		run := collectResult{Ticker: ticker}
+		defer func() {
+			if r := recover(); r != nil {
+				run.Error = fmt.Sprintf("panic: %v", r)
+			}
+			done <- run
+		}()
+
+		outcome := combine()
+		run.Result = outcome.Result
+		if len(outcome.Results) > 1 {
+			run.Sources = outcome.Results
+		}
+		if len(outcome.Diagnostics) > 0 {
+			run.Diagnostics = outcome.Diagnostics
+		}
+		switch err := outcome.Err(); {
+		case outcome.Disagreement != "":
+			run.Error = outcome.Disagreement
+		case err != nil && run.Result.TotalAsset == 0:
+			run.Error = err.Error()
+		case run.Result.TotalAsset == 0:
+			run.Error = "no holdings returned" + unmatched(outcome.Diagnostics)
+		}
+	}()
+
+	var run collectResult
+	select {
+	case run = <-done:
+	case <-time.After(timeout):
+		run = collectResult{Ticker: ticker, Error: "timed out after " + timeout.String()}
+	}
+	run.Latency = time.Since(start).Round(time.Millisecond).String()
+
+	return run
+}
+
+// unmatched explains an empty result by the selectors that matched nothing
+func unmatched(diagnostics map[string]*scrape.Diagnostics) string {
+	var missing []string
+	for _, diag := range diagnostics {
+		missing = append(missing, diag.Missing()...)
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	sort.Strings(missing)
+	return ", nothing matched " + strings.Join(missing, ", ")
+}
+
+// runCollect implements the collect subcommand. It runs the selected
+// collectors once in parallel and returns the process exit code.
+func runCollect(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("collect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	all := fs.Bool("all", false, "Run every collector")
+	asJSON := fs.Bool("json", false, "Print results as JSON")
+	timeout := fs.Duration("timeout", 2*time.Minute, "Time allowed for each collector")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: btcEtfScrape collect [flags] TICKER... | --all")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	tickers := fs.Args()
+	if *all {
+		tickers = tickers[:0]
+		for ticker := range collectors {
+			tickers = append(tickers, ticker)
+		}
+	}
+	if len(tickers) == 0 {
+		fs.Usage()
+		return 2
+	}
+	for i, ticker := range tickers {
+		tickers[i] = strings.ToUpper(ticker)
+		if _, ok := collectors[tickers[i]]; !ok {
+			fmt.Fprintf(stderr, "unknown ticker %q\n", ticker)
+			return 2
+		}
+	}
+	sort.Strings(tickers)
+
+	// Run each fund's sources with the built in policies
+	c := config.Default()
+	runs := make([]collectResult, len(tickers))
+	var wg sync.WaitGroup
+	for i, ticker := range tickers {
+		wg.Add(1)
+		go func(i int, ticker string) {
+			defer wg.Done()
+			// Sources are resolved up front as a timed out run keeps going
+			sources := sourcesFor(c, ticker)
+			runs[i] = collectOnce(ticker, func() funds.Outcome {
+				return funds.Combine(funds.Policy(c.FundPolicy(ticker)), c.FundSourceTolerance(ticker), sources)
+			}, *timeout)
+		}(i, ticker)
+	}
+	wg.Wait()
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(runs)
+	} else {
+		printCollect(stdout, runs)
+	}
+
+	for _, run := range runs {
+		if run.Error != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// printCollect writes the runs as an aligned table
+func printCollect(w io.Writer, runs []collectResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TICKER\tHOLDINGS\tDATE\tLATENCY\tERROR")
+	for _, run := range runs {
+		holdings, date := "", ""
+		if run.Result.TotalAsset != 0 {
+			holdings = humanize.CommafWithDigits(run.Result.TotalAsset, 2)
+		}
+		if !run.Result.Date.IsZero() {
+			date = run.Result.Date.Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", run.Ticker, holdings, date, run.Latency, run.Error)
+	}
+	tw.Flush()
+}
+
+// collectCommand runs the collect subcommand when it is the first argument
+func collectCommand() {
+	if len(os.Args) > 1 && os.Args[1] == "collect" {
+		os.Exit(runCollect(os.Args[2:], os.Stdout, os.Stderr))
+	}
+}
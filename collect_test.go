@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/funds"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/parse"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestRunCollect(t *testing.T) {
+	saved := collectors
+	defer func() { collectors = saved }()
+	source := func(collect func() (types.Result, *scrape.Diagnostics, error)) []funds.Source {
+		return []funds.Source{{Name: "test", Collect: collect}}
+	}
+	collectors = map[string][]funds.Source{
+		"GOOD": source(func() (types.Result, *scrape.Diagnostics, error) {
+			return types.Result{TotalAsset: 1234.5, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, nil, nil
+		}),
+		"EMPTY": source(func() (types.Result, *scrape.Diagnostics, error) { return types.Result{}, nil, nil }),
+		"BAD": source(func() (types.Result, *scrape.Diagnostics, error) {
+			total, err := parse.Number("BAD", "holdings", "n/a")
+			return types.Result{TotalAsset: total}, nil, err
+		}),
+		"PANIC": source(func() (types.Result, *scrape.Diagnostics, error) { panic("index out of range") }),
+		"SLOW": source(func() (types.Result, *scrape.Diagnostics, error) {
+			time.Sleep(time.Second)
+			return types.Result{TotalAsset: 1}, nil, nil
+		}),
+		"SPLIT": {
+			{Name: "page", Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{TotalAsset: 1000}, nil, nil }},
+			{Name: "csv", Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{TotalAsset: 1100}, nil, nil }},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantCode  int
+		wantError map[string]string
+	}{
+		{name: "good", args: []string{"good"}, wantCode: 0, wantError: map[string]string{"GOOD": ""}},
+		{name: "empty", args: []string{"GOOD", "EMPTY"}, wantCode: 1, wantError: map[string]string{"GOOD": "", "EMPTY": "no holdings"}},
+		{name: "parse error", args: []string{"BAD"}, wantCode: 1, wantError: map[string]string{"BAD": `BAD holdings: cannot parse "n/a"`}},
+		{name: "panic", args: []string{"PANIC"}, wantCode: 1, wantError: map[string]string{"PANIC": "panic: index out of range"}},
+		{name: "timeout", args: []string{"-timeout", "10ms", "SLOW"}, wantCode: 1, wantError: map[string]string{"SLOW": "timed out"}},
+		{name: "disagreement", args: []string{"SPLIT"}, wantCode: 1, wantError: map[string]string{"SPLIT": "sources disagree: page 1000.00, csv 1100.00"}},
+		{name: "all", args: []string{"-all", "-timeout", "10ms"}, wantCode: 1, wantError: map[string]string{"GOOD": "", "EMPTY": "no holdings", "BAD": "cannot parse", "PANIC": "panic", "SLOW": "timed out", "SPLIT": "sources disagree"}},
+		{name: "unknown ticker", args: []string{"NOPE"}, wantCode: 2},
+		{name: "no tickers", args: nil, wantCode: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runCollect(append([]string{"-json"}, tt.args...), &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d: %s", code, tt.wantCode, stderr.String())
+			}
+			if tt.wantError == nil {
+				return
+			}
+
+			var runs []collectResult
+			if err := json.Unmarshal(stdout.Bytes(), &runs); err != nil {
+				t.Fatal(err)
+			}
+			if len(runs) != len(tt.wantError) {
+				t.Fatalf("got %d results, want %d", len(runs), len(tt.wantError))
+			}
+			for _, run := range runs {
+				want := tt.wantError[run.Ticker]
+				if (want == "") != (run.Error == "") || !strings.Contains(run.Error, want) {
+					t.Errorf("%s error = %q, want %q", run.Ticker, run.Error, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintCollect(t *testing.T) {
+	var out bytes.Buffer
+	printCollect(&out, []collectResult{
+		{Ticker: "IBIT", Result: types.Result{TotalAsset: 250123.456, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, Latency: "812ms"},
+		{Ticker: "FBTC", Latency: "2.1s", Error: "no holdings returned, see log"},
+	})
+
+	want := "TICKER  HOLDINGS    DATE        LATENCY  ERROR\n" +
+		"IBIT    250,123.45  2024-03-01  812ms    \n" +
+		"FBTC                            2.1s     no holdings returned, see log\n"
+	if out.String() != want {
+		t.Errorf("printCollect() got:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestCollectFund_Disagreement(t *testing.T) {
+	saved := collectors
+	renderer, _ = message.NewRenderer("")
+	var output bytes.Buffer
+	previousWriter := dryRunWriter
+	dryRun, dryRunWriter = true, &output
+	defer func() {
+		collectors, renderer, dryRun, dryRunWriter = saved, nil, false, previousWriter
+	}()
+
+	verifier := 1200.0
+	collectors = map[string][]funds.Source{"SPLIT": {
+		{Name: "primary", Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{TotalAsset: 1000}, nil, nil }},
+		{Name: "verifier", Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{TotalAsset: verifier}, nil, nil }},
+	}}
+
+	// The primary is used while the verifier disagrees, alerted once a day
+	// even as the verifier's value moves
+	for i := 0; i < 3; i++ {
+		outcome := collectFund("SPLIT")
+		if outcome.Result.TotalAsset != 1000 || outcome.Disagreement == "" {
+			t.Fatalf("collectFund() = %+v", outcome)
+		}
+		verifier += 10
+	}
+	if got := strings.Count(output.String(), "kind=alert ticker=SPLIT"); got != 1 {
+		t.Errorf("sent %d disagreement alerts, want 1:\n%s", got, output.String())
+	}
+}
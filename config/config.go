@@ -0,0 +1,625 @@
+/*
+Package config loads the scraper settings from a YAML file.
+
+Settings start from Default, are replaced by any value set in the file, then by
+BTCETF_ environment variables. Fund entries in the file are merged over the
+default fund of the same ticker, so a file only needs the fields it changes.
+*/
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Channels a fund can post to
+var Channels = []string{"discord", "x", "bluesky", "mastodon"}
+
+// Policies for combining a fund's sources: use the primary and alert when the
+// others disagree, use the value most sources agree on, or use the first that
+// answers
+var Policies = []string{"primary", "majority", "first"}
+
+type Config struct {
+	// Listen is the admin HTTP server address
+	Listen string `yaml:"listen"`
+	// PollInterval between scrapes of a fund without its own interval
+	PollInterval Duration `yaml:"pollInterval"`
+	// Backoff after a flow is posted, before polling that fund again
+	Backoff Duration `yaml:"backoff"`
+	// MinBitcoinDiff skips social posts for smaller changes
+	MinBitcoinDiff float64 `yaml:"minBitcoinDiff"`
+	// DailySummary posts the day's flows once a day at SummaryHour New York time
+	DailySummary bool `yaml:"dailySummary"`
+	SummaryHour  int  `yaml:"summaryHour"`
+	// PostInitialization posts each fund's holdings when it is first scraped
+	// after a start, otherwise they are only logged
+	PostInitialization bool `yaml:"postInitialization"`
+	// MaxChangePct quarantines a scrape changing holdings by more than this
+	// percentage until it is confirmed. Zero disables the check.
+	MaxChangePct float64 `yaml:"maxChangePct"`
+	// AllowNonTradingDays accepts scrapes dated on a weekend without confirmation
+	AllowNonTradingDays bool `yaml:"allowNonTradingDays"`
+	// SourceTolerance is how many bitcoin a fund's sources may differ by and
+	// still agree
+	SourceTolerance float64 `yaml:"sourceTolerance"`
+	// PublicationLag is how long after the close funds without their own lag
+	// publish holdings
+	PublicationLag Duration `yaml:"publicationLag"`
+	// StaleTradingDays alerts when a fund's holdings are older than this many
+	// trading days
+	StaleTradingDays int `yaml:"staleTradingDays"`
+	// ReconcileTolerancePct is how far in percent scraped holdings may differ
+	// from those implied by shares outstanding and bitcoin per share before
+	// the scrape is quarantined. Zero disables the check.
+	ReconcileTolerancePct float64 `yaml:"reconcileTolerancePct"`
+	// OpsChannels receive operational alerts such as stale sources
+	OpsChannels []string `yaml:"opsChannels"`
+
+	Discord Discord `yaml:"discord"`
+	Funds   Funds   `yaml:"funds"`
+}
+
+type Discord struct {
+	WebhookURL     string `yaml:"webhookURL"`
+	AvatarUsername string `yaml:"avatarUsername"`
+	AvatarURL      string `yaml:"avatarURL"`
+}
+
+type Fund struct {
+	// Enabled defaults to true
+	Enabled     *bool  `yaml:"enabled"`
+	Description string `yaml:"description"`
+	Note        string `yaml:"note"`
+	URL         string `yaml:"url"`
+	// Delayed funds publish holdings a day late
+	Delayed bool `yaml:"delayed"`
+	// PublicationLag overrides the global lag after the close when set
+	PublicationLag Duration `yaml:"publicationLag"`
+	// PollInterval overrides the global interval when set
+	PollInterval Duration `yaml:"pollInterval"`
+	// PublicationWindow limits polling to when the issuer usually publishes
+	PublicationWindow *Window `yaml:"publicationWindow"`
+	// MinBitcoinDiff overrides the global threshold when set
+	MinBitcoinDiff *float64 `yaml:"minBitcoinDiff"`
+	// MaxChangePct overrides the global quarantine threshold when set
+	MaxChangePct *float64 `yaml:"maxChangePct"`
+	// Channels limits posts to these channels. Empty posts to every channel.
+	Channels []string `yaml:"channels"`
+	// Sources to collect from, the primary first. Empty uses every source.
+	Sources []string `yaml:"sources"`
+	// Policy for combining the sources, primary by default
+	Policy string `yaml:"policy"`
+	// SourceTolerance overrides the global tolerance when set
+	SourceTolerance *float64 `yaml:"sourceTolerance"`
+	// ReconcileTolerancePct overrides the global reconciliation tolerance when set
+	ReconcileTolerancePct *float64 `yaml:"reconcileTolerancePct"`
+}
+
+// Window is a daily New York time range such as 16:00 to 08:00. A window
+// ending before it starts runs past midnight.
+type Window struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// Duration reads values such as "5m" or "12h"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// Funds merges file entries over the existing fund of the same ticker
+type Funds map[string]Fund
+
+var fundKeys = map[string]bool{
+	"enabled": true, "description": true, "note": true, "url": true, "delayed": true,
+	"pollInterval": true, "publicationWindow": true, "minBitcoinDiff": true, "maxChangePct": true, "channels": true,
+	"sources": true, "policy": true, "sourceTolerance": true, "publicationLag": true, "reconcileTolerancePct": true,
+}
+
+func (f *Funds) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: funds must be a mapping of ticker to settings", node.Line)
+	}
+	if *f == nil {
+		*f = Funds{}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		ticker, value := node.Content[i].Value, node.Content[i+1]
+		if value.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: funds.%s must be a mapping", value.Line, ticker)
+		}
+		// Node.Decode ignores unknown fields, so check them here
+		for j := 0; j < len(value.Content); j += 2 {
+			if key := value.Content[j]; !fundKeys[key.Value] {
+				return fmt.Errorf("line %d: funds.%s: unknown field %q", key.Line, ticker, key.Value)
+			}
+		}
+
+		fund := (*f)[ticker]
+		if err := value.Decode(&fund); err != nil {
+			return fmt.Errorf("funds.%s: %w", ticker, err)
+		}
+		(*f)[ticker] = fund
+	}
+	return nil
+}
+
+// Default returns the built in settings
+func Default() *Config {
+	return &Config{
+		Listen:                ":8080",
+		PollInterval:          Duration(5 * time.Minute),
+		Backoff:               Duration(12 * time.Hour),
+		MinBitcoinDiff:        1.0,
+		SummaryHour:           9,
+		MaxChangePct:          20,
+		SourceTolerance:       1,
+		PublicationLag:        Duration(12 * time.Hour),
+		StaleTradingDays:      2,
+		ReconcileTolerancePct: 0.5,
+		OpsChannels:           []string{"discord"},
+		Discord: Discord{
+			WebhookURL:     "https://discord.com/api/webhooks/",
+			AvatarUsername: "Annalee Call",
+			AvatarURL:      "https://static1.personality-database.com/profile_images/6604632de9954b4d99575e56404bd8b7.png",
+		},
+		Funds: Funds{
+			"ARKB": {Description: "Ark 21Shares", Note: "ARKB holdings are usually updated 10+ hours after the close of trading", PublicationLag: Duration(10 * time.Hour), URL: "https://www.ark-funds.com/funds/arkb"},                                                                                                           // ARK 21Shares Bitcoin ETF
+			"BITB": {Description: "Bitwise", Note: "BITB holdings are usually updated 4.5+ hours after the close of trading", PublicationLag: Duration(270 * time.Minute), URL: "https://bitbetf.com"},                                                                                                                             // Bitwise Bitcoin ETF
+			"BRRR": {Description: "Valkyrie", Note: "BRRR holdings are usually updated 10+ hours after the close of trading", PublicationLag: Duration(10 * time.Hour), URL: "https://valkyrieinvest.com/brrr-holdings/"},                                                                                                          // Valkyrie Bitcoin Fund
+			"BTCW": {Description: "WisdomTree", Note: "", URL: "https://www.wisdomtree.com/investments/etfs/crypto/btcw"},                                                                                                                                                                                                          // WisdomTree Bitcoin Fund
+			"DEFI": {Description: "Hashdex", Note: "", URL: "https://hashdex-etfs.com/defi"},                                                                                                                                                                                                                                       // Hashdex Bitcoin ETF
+			"EZBC": {Description: "Franklin", Note: "EZBC holdings are usually updated 5.5+ hours after the close of trading", PublicationLag: Duration(330 * time.Minute), URL: "https://www.franklintempleton.com/investments/options/exchange-traded-funds/products/39639/SINGLCLASS/franklin-bitcoin-etf/EZBC"},                // Franklin Bitcoin ETF
+			"FBTC": {Description: "Fidelity", Note: "FBTC holdings are usually updated 16+ hours after the close of trading", PublicationLag: Duration(16 * time.Hour), URL: "https://fundresearch.fidelity.com/prospectus/eproredirect?clientId=Fidelity&applicationId=MFL&securityIdType=CUSIP&critical=N&securityId=315948109"}, // Fidelity Wise Origin Bitcoin Fund
+			"GBTC": {Description: "Grayscale", Note: "GBTC holdings are usually updated 1 day late", Delayed: true, URL: "https://etfs.grayscale.com/gbtc"},                                                                                                                                                                        // Grayscale Bitcoin Trust
+			"HODL": {Description: "VanEck", Note: "HODL holdings are usually updated 1 day late", Delayed: true, URL: "https://www.vaneck.com/us/en/investments/bitcoin-etf-hodl/"},                                                                                                                                                // VanEck Bitcoin Trust
+			"IBIT": {Description: "BlackRock", Note: "IBIT holdings are usually updated 13+ hours after the close of trading", PublicationLag: Duration(13 * time.Hour), URL: "https://www.ishares.com/us/products/333011/ishares-bitcoin-trust"},                                                                                  // iShares Bitcoin Trust
+		},
+		// BTCO - Invesco Galaxy Bitcoin ETF
+	}
+}
+
+// Load reads the file at path over the defaults, applies environment
+// overrides and validates the result. An empty path uses the defaults.
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := c.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// EnvPrefix starts every environment override
+const EnvPrefix = "BTCETF_"
+
+// ApplyEnv replaces settings from environment variables such as
+// BTCETF_POLL_INTERVAL, BTCETF_DISCORD_WEBHOOK_URL or, per fund,
+// BTCETF_IBIT_ENABLED and BTCETF_IBIT_CHANNELS (comma separated)
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	set := func(name string, apply func(string) error) {
+		value, ok := lookup(EnvPrefix + name)
+		if !ok {
+			return
+		}
+		if err := apply(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+		}
+	}
+
+	set("LISTEN", setString(&c.Listen))
+	set("POLL_INTERVAL", setDuration(&c.PollInterval))
+	set("BACKOFF", setDuration(&c.Backoff))
+	set("MIN_BITCOIN_DIFF", setFloat(&c.MinBitcoinDiff))
+	set("DAILY_SUMMARY", setBool(&c.DailySummary))
+	set("SUMMARY_HOUR", func(value string) error {
+		hour, err := strconv.Atoi(value)
+		c.SummaryHour = hour
+		return err
+	})
+	set("POST_INITIALIZATION", setBool(&c.PostInitialization))
+	set("MAX_CHANGE_PCT", setFloat(&c.MaxChangePct))
+	set("ALLOW_NON_TRADING_DAYS", setBool(&c.AllowNonTradingDays))
+	set("SOURCE_TOLERANCE", setFloat(&c.SourceTolerance))
+	set("PUBLICATION_LAG", setDuration(&c.PublicationLag))
+	set("STALE_TRADING_DAYS", func(value string) error {
+		days, err := strconv.Atoi(value)
+		c.StaleTradingDays = days
+		return err
+	})
+	set("RECONCILE_TOLERANCE_PCT", setFloat(&c.ReconcileTolerancePct))
+	set("OPS_CHANNELS", setList(&c.OpsChannels))
+	set("DISCORD_WEBHOOK_URL", setString(&c.Discord.WebhookURL))
+	set("DISCORD_AVATAR_USERNAME", setString(&c.Discord.AvatarUsername))
+	set("DISCORD_AVATAR_URL", setString(&c.Discord.AvatarURL))
+
+	for _, ticker := range c.Tickers(false) {
+		fund := c.Funds[ticker]
+		set(ticker+"_ENABLED", func(value string) error {
+			enabled, err := strconv.ParseBool(value)
+			fund.Enabled = &enabled
+			return err
+		})
+		set(ticker+"_NOTE", setString(&fund.Note))
+		set(ticker+"_POLL_INTERVAL", setDuration(&fund.PollInterval))
+		set(ticker+"_MIN_BITCOIN_DIFF", func(value string) error {
+			var diff float64
+			fund.MinBitcoinDiff = &diff
+			return setFloat(&diff)(value)
+		})
+		set(ticker+"_MAX_CHANGE_PCT", func(value string) error {
+			var pct float64
+			fund.MaxChangePct = &pct
+			return setFloat(&pct)(value)
+		})
+		set(ticker+"_CHANNELS", setList(&fund.Channels))
+		set(ticker+"_SOURCES", setList(&fund.Sources))
+		set(ticker+"_POLICY", setString(&fund.Policy))
+		set(ticker+"_PUBLICATION_LAG", setDuration(&fund.PublicationLag))
+		set(ticker+"_RECONCILE_TOLERANCE_PCT", func(value string) error {
+			var pct float64
+			fund.ReconcileTolerancePct = &pct
+			return setFloat(&pct)(value)
+		})
+		c.Funds[ticker] = fund
+	}
+
+	return errors.Join(errs...)
+}
+
+func setString(target *string) func(string) error {
+	return func(value string) error {
+		*target = value
+		return nil
+	}
+}
+
+func setDuration(target *Duration) func(string) error {
+	return func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		*target = Duration(parsed)
+		return err
+	}
+}
+
+func setFloat(target *float64) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		*target = parsed
+		return err
+	}
+}
+
+func setBool(target *bool) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		*target = parsed
+		return err
+	}
+}
+
+// setList splits a comma separated value
+func setList(target *[]string) func(string) error {
+	return func(value string) error {
+		*target = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*target = append(*target, item)
+			}
+		}
+		return nil
+	}
+}
+
+var tickerPattern = regexp.MustCompile(`^[A-Z]{2,5}$`)
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Listen == "" {
+		invalid("listen", "must not be empty")
+	}
+	if c.PollInterval <= 0 {
+		invalid("pollInterval", "must be greater than zero")
+	}
+	if c.Backoff < 0 {
+		invalid("backoff", "must not be negative")
+	}
+	if c.MinBitcoinDiff < 0 {
+		invalid("minBitcoinDiff", "must not be negative")
+	}
+	if c.MaxChangePct < 0 {
+		invalid("maxChangePct", "must not be negative")
+	}
+	if c.SourceTolerance < 0 {
+		invalid("sourceTolerance", "must not be negative")
+	}
+	if c.PublicationLag < 0 {
+		invalid("publicationLag", "must not be negative")
+	}
+	if c.StaleTradingDays < 1 {
+		invalid("staleTradingDays", "must be at least 1")
+	}
+	if c.ReconcileTolerancePct < 0 {
+		invalid("reconcileTolerancePct", "must not be negative")
+	}
+	for _, channel := range c.OpsChannels {
+		if !knownChannel(channel) {
+			invalid("opsChannels", "unknown channel %q, want one of %s", channel, strings.Join(Channels, ", "))
+		}
+	}
+	if c.SummaryHour < 0 || c.SummaryHour > 23 {
+		invalid("summaryHour", "%d is not an hour from 0 to 23", c.SummaryHour)
+	}
+	if u, err := url.Parse(c.Discord.WebhookURL); err != nil || u.Scheme != "https" {
+		invalid("discord.webhookURL", "%q is not an https URL", c.Discord.WebhookURL)
+	}
+
+	enabled := 0
+	for _, ticker := range c.Tickers(false) {
+		fund := c.Funds[ticker]
+		field := "funds." + ticker
+		if !tickerPattern.MatchString(ticker) {
+			invalid(field, "ticker must be 2 to 5 upper case letters")
+		}
+		if fund.Description == "" {
+			invalid(field+".description", "must not be empty")
+		}
+		if fund.PollInterval < 0 {
+			invalid(field+".pollInterval", "must not be negative")
+		}
+		if fund.PublicationLag < 0 {
+			invalid(field+".publicationLag", "must not be negative")
+		}
+		if fund.MinBitcoinDiff != nil && *fund.MinBitcoinDiff < 0 {
+			invalid(field+".minBitcoinDiff", "must not be negative")
+		}
+		if fund.MaxChangePct != nil && *fund.MaxChangePct < 0 {
+			invalid(field+".maxChangePct", "must not be negative")
+		}
+		if w := fund.PublicationWindow; w != nil {
+			start, startErr := parseClock(w.Start)
+			end, endErr := parseClock(w.End)
+			if startErr != nil {
+				invalid(field+".publicationWindow.start", "%q is not HH:MM", w.Start)
+			}
+			if endErr != nil {
+				invalid(field+".publicationWindow.end", "%q is not HH:MM", w.End)
+			}
+			if startErr == nil && endErr == nil && start == end {
+				invalid(field+".publicationWindow", "start and end are both %s", w.Start)
+			}
+		}
+		for _, channel := range fund.Channels {
+			if !knownChannel(channel) {
+				invalid(field+".channels", "unknown channel %q, want one of %s", channel, strings.Join(Channels, ", "))
+			}
+		}
+		seen := map[string]bool{}
+		for _, source := range fund.Sources {
+			if seen[source] {
+				invalid(field+".sources", "%q is listed twice", source)
+			}
+			seen[source] = true
+		}
+		if fund.Policy != "" && !knownPolicy(fund.Policy) {
+			invalid(field+".policy", "unknown policy %q, want one of %s", fund.Policy, strings.Join(Policies, ", "))
+		}
+		if fund.SourceTolerance != nil && *fund.SourceTolerance < 0 {
+			invalid(field+".sourceTolerance", "must not be negative")
+		}
+		if fund.ReconcileTolerancePct != nil && *fund.ReconcileTolerancePct < 0 {
+			invalid(field+".reconcileTolerancePct", "must not be negative")
+		}
+		if fund.IsEnabled() {
+			enabled++
+		}
+	}
+	if enabled == 0 {
+		invalid("funds", "no fund is enabled")
+	}
+
+	return errors.Join(errs...)
+}
+
+func knownChannel(channel string) bool {
+	for _, known := range Channels {
+		if channel == known {
+			return true
+		}
+	}
+	return false
+}
+
+func knownPolicy(policy string) bool {
+	for _, known := range Policies {
+		if policy == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Tickers returns the configured tickers in order, only the enabled ones
+// when enabledOnly is set
+func (c *Config) Tickers(enabledOnly bool) []string {
+	var tickers []string
+	for ticker, fund := range c.Funds {
+		if !enabledOnly || fund.IsEnabled() {
+			tickers = append(tickers, ticker)
+		}
+	}
+	sort.Strings(tickers)
+	return tickers
+}
+
+// FundPollInterval is the fund's own interval or the global one
+func (c *Config) FundPollInterval(ticker string) time.Duration {
+	if interval := c.Funds[ticker].PollInterval; interval > 0 {
+		return time.Duration(interval)
+	}
+	return time.Duration(c.PollInterval)
+}
+
+// FundMinBitcoinDiff is the fund's own threshold or the global one
+func (c *Config) FundMinBitcoinDiff(ticker string) float64 {
+	if diff := c.Funds[ticker].MinBitcoinDiff; diff != nil {
+		return *diff
+	}
+	return c.MinBitcoinDiff
+}
+
+// FundMaxChangePct is the fund's own quarantine threshold or the global one
+func (c *Config) FundMaxChangePct(ticker string) float64 {
+	if pct := c.Funds[ticker].MaxChangePct; pct != nil {
+		return *pct
+	}
+	return c.MaxChangePct
+}
+
+// FundPublicationLag is the fund's own lag after the close or the global one
+func (c *Config) FundPublicationLag(ticker string) time.Duration {
+	if lag := c.Funds[ticker].PublicationLag; lag > 0 {
+		return time.Duration(lag)
+	}
+	return time.Duration(c.PublicationLag)
+}
+
+// FundPolicy is the fund's policy for combining its sources
+func (c *Config) FundPolicy(ticker string) string {
+	if policy := c.Funds[ticker].Policy; policy != "" {
+		return policy
+	}
+	return "primary"
+}
+
+// FundSourceTolerance is the fund's own source tolerance or the global one
+func (c *Config) FundSourceTolerance(ticker string) float64 {
+	if tolerance := c.Funds[ticker].SourceTolerance; tolerance != nil {
+		return *tolerance
+	}
+	return c.SourceTolerance
+}
+
+// FundReconcileTolerancePct is the fund's own reconciliation tolerance or the
+// global one
+func (c *Config) FundReconcileTolerancePct(ticker string) float64 {
+	if pct := c.Funds[ticker].ReconcileTolerancePct; pct != nil {
+		return *pct
+	}
+	return c.ReconcileTolerancePct
+}
+
+func (f Fund) IsEnabled() bool {
+	return f.Enabled == nil || *f.Enabled
+}
+
+// PostsTo reports whether the fund posts to a channel
+func (f Fund) PostsTo(channel string) bool {
+	if len(f.Channels) == 0 {
+		return true
+	}
+	for _, allowed := range f.Channels {
+		if allowed == channel {
+			return true
+		}
+	}
+	return false
+}
+
+var newYork = loadNewYork()
+
+func loadNewYork() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// parseClock returns minutes after midnight for "HH:MM"
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Wait returns how long until the window next opens, zero when t is inside
+// it or the window is not set
+func (w *Window) Wait(t time.Time) time.Duration {
+	if w == nil {
+		return 0
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return 0
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return 0
+	}
+
+	local := t.In(newYork)
+	now := local.Hour()*60 + local.Minute()
+	inside := now >= start && now < end
+	if end < start {
+		inside = now >= start || now < end
+	}
+	if inside {
+		return 0
+	}
+
+	next := time.Date(local.Year(), local.Month(), local.Day(), start/60, start%60, 0, 0, newYork)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(t)
+}
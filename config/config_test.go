@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Listen != ":9090" || time.Duration(c.PollInterval) != 10*time.Minute || c.MinBitcoinDiff != 5 {
+		t.Errorf("globals = %q %s %v", c.Listen, time.Duration(c.PollInterval), c.MinBitcoinDiff)
+	}
+	if time.Duration(c.Backoff) != 12*time.Hour {
+		t.Errorf("backoff = %s, want the 12h default", time.Duration(c.Backoff))
+	}
+	if c.Discord.AvatarUsername != "Bishop" || c.Discord.WebhookURL != Default().Discord.WebhookURL {
+		t.Errorf("discord = %+v", c.Discord)
+	}
+
+	ibit := c.Funds["IBIT"]
+	if ibit.Description != "BlackRock" || ibit.Note != "IBIT holdings are published after 8pm" {
+		t.Errorf("IBIT not merged over the default: %+v", ibit)
+	}
+	if c.FundMinBitcoinDiff("IBIT") != 25 || c.FundMinBitcoinDiff("FBTC") != 5 {
+		t.Errorf("FundMinBitcoinDiff = %v, %v", c.FundMinBitcoinDiff("IBIT"), c.FundMinBitcoinDiff("FBTC"))
+	}
+	if !ibit.PostsTo("x") || ibit.PostsTo("mastodon") || !c.Funds["FBTC"].PostsTo("mastodon") {
+		t.Error("PostsTo does not follow channels")
+	}
+
+	tickers := c.Tickers(true)
+	if len(tickers) != 9 || strings.Contains(strings.Join(tickers, ","), "HODL") {
+		t.Errorf("Tickers(true) = %v, want every default but HODL", tickers)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{name: "unknown field", yaml: "pollIntervall: 5m\n", want: []string{"pollIntervall"}},
+		{name: "unknown fund field", yaml: "funds:\n  IBIT:\n    notes: typo\n", want: []string{"line 3", "funds.IBIT", `"notes"`}},
+		{name: "bad duration", yaml: "backoff: soon\n", want: []string{"line 1", "soon"}},
+		{
+			name: "bad sources",
+			yaml: "funds:\n  IBIT:\n    sources: [ajax-json, ajax-json]\n    policy: vote\n    sourceTolerance: -1\n",
+			want: []string{
+				`funds.IBIT.sources: "ajax-json" is listed twice`,
+				`funds.IBIT.policy: unknown policy "vote"`,
+				"funds.IBIT.sourceTolerance: must not be negative",
+			},
+		},
+		{
+			name: "negative reconcile tolerance",
+			yaml: "reconcileTolerancePct: -1\nfunds:\n  GBTC:\n    reconcileTolerancePct: -0.5\n",
+			want: []string{"reconcileTolerancePct: must not be negative", "funds.GBTC.reconcileTolerancePct: must not be negative"},
+		},
+		{
+			name: "every invalid setting",
+			yaml: "pollInterval: 0s\nsummaryHour: 24\nfunds:\n  IBIT:\n    channels: [telegram]\n    publicationWindow: {start: \"25:00\", end: \"09:00\"}\n  new:\n    note: x\n",
+			want: []string{
+				"pollInterval: must be greater than zero",
+				"summaryHour: 24 is not an hour",
+				`funds.IBIT.channels: unknown channel "telegram"`,
+				`funds.IBIT.publicationWindow.start: "25:00" is not HH:MM`,
+				"funds.new: ticker must be 2 to 5 upper case letters",
+				"funds.new.description: must not be empty",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load(path)
+			if err == nil {
+				t.Fatal("Load() succeeded, want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"BTCETF_POLL_INTERVAL":         "1m",
+		"BTCETF_DAILY_SUMMARY":         "true",
+		"BTCETF_DISCORD_WEBHOOK_URL":   "https://discord.com/api/webhooks/1/abc",
+		"BTCETF_FBTC_ENABLED":          "false",
+		"BTCETF_IBIT_CHANNELS":         "discord, bluesky",
+		"BTCETF_IBIT_MIN_BITCOIN_DIFF": "50",
+		"BTCETF_IBIT_SOURCES":          "ajax-json,holdings-csv",
+		"BTCETF_IBIT_POLICY":           "majority",
+	}
+	c := Default()
+	err := c.ApplyEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Duration(c.PollInterval) != time.Minute || c.Discord.WebhookURL != env["BTCETF_DISCORD_WEBHOOK_URL"] {
+		t.Errorf("globals not overridden: %+v", c)
+	}
+	if !c.DailySummary || c.PostInitialization {
+		t.Errorf("DailySummary = %v, PostInitialization = %v, want only the summary enabled", c.DailySummary, c.PostInitialization)
+	}
+	if c.Funds["FBTC"].IsEnabled() {
+		t.Error("FBTC still enabled")
+	}
+	if channels := c.Funds["IBIT"].Channels; len(channels) != 2 || channels[1] != "bluesky" {
+		t.Errorf("IBIT channels = %v", channels)
+	}
+	if sources := c.Funds["IBIT"].Sources; len(sources) != 2 || c.FundPolicy("IBIT") != "majority" {
+		t.Errorf("IBIT sources = %v, policy = %s", sources, c.FundPolicy("IBIT"))
+	}
+	if c.FundMinBitcoinDiff("IBIT") != 50 {
+		t.Errorf("IBIT min diff = %v", c.FundMinBitcoinDiff("IBIT"))
+	}
+
+	err = c.ApplyEnv(func(name string) (string, bool) {
+		if name == "BTCETF_BACKOFF" {
+			return "later", true
+		}
+		return "", false
+	})
+	if err == nil || !strings.Contains(err.Error(), "BTCETF_BACKOFF") {
+		t.Errorf("ApplyEnv() error = %v, want BTCETF_BACKOFF error", err)
+	}
+}
+
+func TestWindow_Wait(t *testing.T) {
+	overnight := &Window{Start: "16:00", End: "09:00"}
+	daytime := &Window{Start: "09:30", End: "16:00"}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 4, hour, minute, 0, 0, newYork)
+	}
+
+	tests := []struct {
+		name   string
+		window *Window
+		at     time.Time
+		want   time.Duration
+	}{
+		{name: "no window", window: nil, at: at(12, 0), want: 0},
+		{name: "overnight evening", window: overnight, at: at(20, 0), want: 0},
+		{name: "overnight morning", window: overnight, at: at(8, 59), want: 0},
+		{name: "overnight closed", window: overnight, at: at(12, 0), want: 4 * time.Hour},
+		{name: "daytime open", window: daytime, at: at(9, 30), want: 0},
+		{name: "daytime closed at end", window: daytime, at: at(16, 0), want: 17*time.Hour + 30*time.Minute},
+		{name: "daytime before start", window: daytime, at: at(9, 0), want: 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Wait(tt.at); got != tt.want {
+				t.Errorf("Wait() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+//go:embed web/dashboard.html
+var dashboardHTML []byte
+
+const (
+	statusWaiting   = "waiting"
+	statusOK        = "ok"
+	statusNoData    = "no data"
+	statusError     = "scrape error"
+	statusOverride  = "override applied"
+	statusStaleDate = "result older than current"
+)
+
+// fundStatus is what the dashboard shows beyond the accepted result
+type fundStatus struct {
+	LastScrape time.Time
+	LastFlow   *message.Event
+	Status     string
+	// Error of the last collector run, set even when another source answered
+	Error string
+	// LastSuccess is the last scrape returning holdings and LastAsOf their date
+	LastSuccess time.Time
+	LastAsOf    time.Time
+	// LastChange is when the holdings returned last differed from LastTotal,
+	// the previous holdings returned. Staleness is measured from LastSuccess
+	// instead, as funds without flows return the same holdings.
+	LastChange time.Time
+	LastTotal  float64
+}
+
+var tickerStatus = map[string]fundStatus{}
+
+// recordScrape notes a collector run or an applied override at a time. A run
+// returning holdings counts as the fund updating for the missing update
+// rules, changed or not, as a day without flows republishes the same holdings.
+func recordScrape(ticker string, at time.Time, result types.Result, override bool, err error) {
+	if result.TotalAsset != 0 && rulesEngine != nil {
+		rulesEngine.Seen(ticker, at)
+	}
+
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	status := tickerStatus[ticker]
+	status.LastScrape = at
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+	}
+	switch {
+	case override:
+		status.Status = statusOverride
+	case result.TotalAsset == 0 && err != nil:
+		status.Status = statusError
+	case result.TotalAsset == 0:
+		status.Status = statusNoData
+	default:
+		status.Status = statusOK
+		status.LastSuccess = status.LastScrape
+		if !result.Date.IsZero() {
+			status.LastAsOf = result.Date
+		}
+		if result.TotalAsset != status.LastTotal {
+			status.LastChange, status.LastTotal = status.LastScrape, result.TotalAsset
+		}
+	}
+	tickerStatus[ticker] = status
+}
+
+func setStatus(ticker, value string) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	status := tickerStatus[ticker]
+	status.Status = value
+	tickerStatus[ticker] = status
+}
+
+func setLastFlow(ticker string, event message.Event) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	status := tickerStatus[ticker]
+	status.LastFlow = &event
+	tickerStatus[ticker] = status
+}
+
+// fundState is one row of the dashboard
+type fundState struct {
+	Ticker      string           `json:"ticker"`
+	Description string           `json:"description"`
+	Result      types.Result     `json:"result"`
+	Override    *types.Result    `json:"override,omitempty"`
+	Quarantine  *quarantineEntry `json:"quarantine,omitempty"`
+	LastFlow    *message.Event   `json:"lastFlow,omitempty"`
+	LastScrape  time.Time        `json:"lastScrape"`
+	Status      string           `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Staleness   staleness        `json:"staleness"`
+}
+
+// snapshotState returns every ticker's state sorted by ticker
+func snapshotState() []fundState {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	c, now := conf(), time.Now()
+	states := make([]fundState, 0, len(details()))
+	for ticker, detail := range details() {
+		status := tickerStatus[ticker]
+		state := fundState{
+			Ticker:      ticker,
+			Description: detail.Description,
+			Result:      tickerResults[ticker],
+			LastFlow:    status.LastFlow,
+			LastScrape:  status.LastScrape,
+			Status:      status.Status,
+			Error:       status.Error,
+			Staleness:   fundStaleness(c, ticker, status, now),
+		}
+		if state.Status == "" {
+			state.Status = statusWaiting
+		}
+		if override := tickerResultsOverride[ticker]; override.TotalAsset != 0 {
+			state.Override = &override
+		}
+		if held, ok := quarantined[ticker]; ok {
+			state.Quarantine = &held
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Ticker < states[j].Ticker
+	})
+
+	return states
+}
+
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+func handleState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, snapshotState())
+}
+
+// flowPreview is the message a manual change would produce
+type flowPreview struct {
+	Kind       message.Kind  `json:"kind"`
+	Event      message.Event `json:"event"`
+	Discord    string        `json:"discord"`
+	X          string        `json:"x"`
+	Suppressed bool          `json:"suppressed"`
+	Note       string        `json:"note,omitempty"`
+}
+
+// previewManualData renders what applying data would post without changing any state
+func previewManualData(data manualData, updateType string) (flowPreview, error) {
+	current := currentResult(data.Ticker)
+	newResult := types.Result{TotalAsset: data.Result.TotalAsset, Date: data.Result.Date}
+
+	if current.TotalAsset == 0 {
+		return flowPreview{Note: "No current result, this initializes " + data.Ticker}, nil
+	}
+
+	kind, event := buildFlowEvent(data.Ticker, current, newResult, updateType == "override")
+	preview := flowPreview{
+		Kind:       kind,
+		Event:      event,
+		Suppressed: math.Abs(event.AssetDiff) <= conf().FundMinBitcoinDiff(data.Ticker),
+	}
+	if updateType == "update" {
+		preview.Note = "An update replaces the current result without posting. The next scrape is compared against it."
+		return preview, nil
+	}
+
+	var err error
+	if preview.Discord, err = templates().Render(message.Discord, kind, event); err != nil {
+		return flowPreview{}, err
+	}
+	if preview.X, err = templates().Render(message.X, kind, event); err != nil {
+		return flowPreview{}, err
+	}
+
+	return preview, nil
+}
+
+// handlePreview validates a manual change and returns its preview
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Type string
+		manualData
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if request.Type != "override" && request.Type != "update" {
+		writeJSONError(w, http.StatusUnprocessableEntity, `type must be "override" or "update"`)
+		return
+	}
+	if err := validateManualData(request.manualData, request.Type); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	preview, err := previewManualData(request.manualData, request.Type)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
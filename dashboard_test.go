@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlePreview(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantNote   string
+	}{
+		{name: "initialization", body: `{"Type": "override", "Ticker": "FBTC", "Result": {"TotalAsset": 1000}}`, wantStatus: http.StatusOK, wantNote: "No current result, this initializes FBTC"},
+		{name: "bad type", body: `{"Type": "delete", "Ticker": "FBTC", "Result": {"TotalAsset": 1000}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown ticker", body: `{"Type": "update", "Ticker": "XXXX", "Result": {"TotalAsset": 1000}}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "unknown field", body: `{"Type": "update", "Tikcer": "FBTC"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handlePreview(w, httptest.NewRequest(http.MethodPost, "/preview", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var preview flowPreview
+			if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+				t.Fatal(err)
+			}
+			if preview.Note != tt.wantNote {
+				t.Errorf("note = %q, want %q", preview.Note, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestSnapshotState(t *testing.T) {
+	states := snapshotState()
+	if len(states) != len(tickerDetails) {
+		t.Fatalf("got %d rows, want %d", len(states), len(tickerDetails))
+	}
+	for i := 1; i < len(states); i++ {
+		if states[i-1].Ticker >= states[i].Ticker {
+			t.Errorf("rows not sorted: %s before %s", states[i-1].Ticker, states[i].Ticker)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/message"
+)
+
+// Rule name of the alert sent when an issuer page's structure changes
+const driftRule = "selector-drift"
+
+// driftState is the latest structure seen on an HTML source
+type driftState struct {
+	Ticker      string              `json:"ticker"`
+	Source      string              `json:"source"`
+	Checked     time.Time           `json:"checked"`
+	Diagnostics *scrape.Diagnostics `json:"diagnostics"`
+	// Baseline is the fingerprint the next visit is compared with
+	Baseline string `json:"baseline"`
+	Drift    string `json:"drift,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+var (
+	// Keyed by ticker and source
+	driftStates = map[string]driftState{}
+	driftMu     sync.Mutex
+)
+
+// drifted returns how a page differs from the structure the collector
+// expects, or an empty string when it matches
+func drifted(baseline string, diag *scrape.Diagnostics) string {
+	var reasons []string
+	if missing := diag.Missing(); len(missing) > 0 {
+		reasons = append(reasons, "nothing matched "+strings.Join(missing, ", "))
+	}
+	if baseline != "" && diag.Fingerprint != "" && diag.Fingerprint != baseline {
+		reasons = append(reasons, fmt.Sprintf("page structure changed, fingerprint %s was %s", diag.Fingerprint, baseline))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// checkDrift compares a visit with the previous one. A new drift saves the
+// page and alerts the ops channels. The changed structure becomes the new
+// baseline, and a drift seen again on the next visit is not saved or alerted
+// again.
+func checkDrift(ticker, source string, diag *scrape.Diagnostics) {
+	key := ticker + "/" + source
+
+	driftMu.Lock()
+	state := driftStates[key]
+	previous := state
+	state.Ticker, state.Source, state.Checked, state.Diagnostics = ticker, source, time.Now(), diag
+	// A failed fetch says nothing about the page structure
+	if diag.StatusCode == http.StatusOK {
+		state.Drift = drifted(state.Baseline, diag)
+		if diag.Fingerprint != "" {
+			state.Baseline = diag.Fingerprint
+		}
+		if state.Drift != previous.Drift {
+			state.Snapshot = ""
+		}
+	}
+	driftStates[key] = state
+	if state.Baseline != previous.Baseline || state.Drift != previous.Drift {
+		saveDriftStates()
+	}
+	driftMu.Unlock()
+
+	if state.Drift == "" || state.Drift == previous.Drift {
+		return
+	}
+
+	reason := fmt.Sprintf("%s on %s: %s", source, diag.URL, state.Drift)
+	if path, err := saveSnapshot(ticker, source, diag); err != nil {
+		log.Printf("%s snapshot error: %v", ticker, err)
+	} else if path != "" {
+		reason += ", page saved to " + path
+		driftMu.Lock()
+		state.Snapshot = path
+		driftStates[key] = state
+		saveDriftStates()
+		driftMu.Unlock()
+	}
+	log.Printf("%s drift %s, selectors %+v, %d candidates", ticker, reason, diag.Selectors, diag.Candidates)
+
+	notifyOps(driftRule, message.Event{Ticker: ticker}, reason)
+}
+
+// loadDriftStates restores the structure each source was last seen with, so a
+// restart neither loses a baseline nor alerts a known drift again
+func loadDriftStates(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var states []driftState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return err
+	}
+
+	driftMu.Lock()
+	defer driftMu.Unlock()
+	for _, state := range states {
+		driftStates[state.Ticker+"/"+state.Source] = state
+	}
+	return nil
+}
+
+// saveDriftStates writes the drift states to driftStatePath, called with
+// driftMu held
+func saveDriftStates() {
+	if driftStatePath == "" {
+		return
+	}
+
+	states := make([]driftState, 0, len(driftStates))
+	for _, state := range driftStates {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Ticker+"/"+states[i].Source < states[j].Ticker+"/"+states[j].Source
+	})
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err == nil {
+		tmp := driftStatePath + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, driftStatePath)
+		}
+	}
+	if err != nil {
+		log.Println("Drift state save error:", err)
+	}
+}
+
+// saveSnapshot writes the page a drift was seen on, returning its path
+func saveSnapshot(ticker, source string, diag *scrape.Diagnostics) (string, error) {
+	if snapshotDir == "" || len(diag.Snapshot) == 0 {
+		return "", nil
+	}
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s-%s.html", ticker, source, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(snapshotDir, name)
+	return path, os.WriteFile(path, diag.Snapshot, 0644)
+}
+
+// handleDiagnostics lists the latest structure seen on every HTML source
+func handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	driftMu.Lock()
+	states := make([]driftState, 0, len(driftStates))
+	for _, state := range driftStates {
+		states = append(states, state)
+	}
+	driftMu.Unlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Ticker != states[j].Ticker {
+			return states[i].Ticker < states[j].Ticker
+		}
+		return states[i].Source < states[j].Source
+	})
+	writeJSON(w, http.StatusOK, states)
+}
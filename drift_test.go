@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/message"
+)
+
+func TestCheckDrift(t *testing.T) {
+	snapshotDir = t.TempDir()
+	renderer, _ = message.NewRenderer("")
+	var output bytes.Buffer
+	previousWriter := dryRunWriter
+	dryRun, dryRunWriter = true, &output
+	defer func() {
+		snapshotDir, renderer, dryRun, dryRunWriter = "", nil, false, previousWriter
+		driftMu.Lock()
+		delete(driftStates, "BRRR/holdings-page")
+		driftMu.Unlock()
+	}()
+
+	visit := func(fingerprint string, status, matches int) driftState {
+		diag := &scrape.Diagnostics{
+			URL:         "https://example.com/holdings",
+			StatusCode:  status,
+			Selectors:   []scrape.Selector{{Selector: "table tbody tr", Matches: matches}},
+			Fingerprint: fingerprint,
+			Snapshot:    []byte("<html></html>"),
+		}
+		checkDrift("BRRR", "holdings-page", diag)
+
+		driftMu.Lock()
+		defer driftMu.Unlock()
+		return driftStates["BRRR/holdings-page"]
+	}
+
+	if state := visit("aaaa", http.StatusOK, 3); state.Drift != "" || state.Baseline != "aaaa" {
+		t.Fatalf("first visit = %+v, want a baseline without drift", state)
+	}
+	if state := visit("aaaa", http.StatusOK, 3); state.Drift != "" {
+		t.Fatalf("unchanged visit drift = %q", state.Drift)
+	}
+	if state := visit("", http.StatusServiceUnavailable, 0); state.Drift != "" {
+		t.Fatalf("failed fetch drift = %q", state.Drift)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("alerted without a drift: %s", output.String())
+	}
+
+	state := visit("bbbb", http.StatusOK, 3)
+	if !strings.Contains(state.Drift, "fingerprint bbbb was aaaa") || state.Baseline != "bbbb" {
+		t.Errorf("changed visit = %+v", state)
+	}
+	if data, err := os.ReadFile(state.Snapshot); err != nil || string(data) != "<html></html>" {
+		t.Errorf("snapshot %s = %q, %v", state.Snapshot, data, err)
+	}
+	if filepath.Dir(state.Snapshot) != snapshotDir {
+		t.Errorf("snapshot saved to %s, want %s", state.Snapshot, snapshotDir)
+	}
+	if !strings.Contains(output.String(), "kind=alert ticker=BRRR") {
+		t.Errorf("no drift alert sent: %s", output.String())
+	}
+
+	unmatched := visit("", http.StatusOK, 0)
+	if !strings.Contains(unmatched.Drift, "nothing matched table tbody tr") || unmatched.Snapshot == "" {
+		t.Fatalf("unmatched visit = %+v", unmatched)
+	}
+
+	// The same drift on the next visit keeps its snapshot and is not alerted again
+	output.Reset()
+	saved, _ := os.ReadDir(snapshotDir)
+	if state := visit("", http.StatusOK, 0); state.Drift != unmatched.Drift || state.Snapshot != unmatched.Snapshot {
+		t.Errorf("repeated drift = %+v, want %+v", state, unmatched)
+	}
+	if snapshots, _ := os.ReadDir(snapshotDir); len(snapshots) != len(saved) {
+		t.Errorf("%d snapshots after a repeated drift, want %d", len(snapshots), len(saved))
+	}
+	if output.Len() != 0 {
+		t.Errorf("repeated drift alerted: %s", output.String())
+	}
+}
+
+func TestDriftStates_Persist(t *testing.T) {
+	driftStatePath = filepath.Join(t.TempDir(), "drift_state.json")
+	defer func() {
+		driftStatePath = ""
+		driftMu.Lock()
+		delete(driftStates, "BITB/website")
+		driftMu.Unlock()
+	}()
+
+	checkDrift("BITB", "website", &scrape.Diagnostics{
+		URL:         "https://example.com",
+		StatusCode:  http.StatusOK,
+		Selectors:   []scrape.Selector{{Selector: "div", Matches: 1}},
+		Fingerprint: "aaaa",
+	})
+
+	// As if restarted
+	driftMu.Lock()
+	delete(driftStates, "BITB/website")
+	driftMu.Unlock()
+	if err := loadDriftStates(driftStatePath); err != nil {
+		t.Fatal(err)
+	}
+
+	driftMu.Lock()
+	defer driftMu.Unlock()
+	if state := driftStates["BITB/website"]; state.Baseline != "aaaa" {
+		t.Errorf("restored state = %+v, want baseline aaaa", state)
+	}
+}
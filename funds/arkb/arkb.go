@@ -2,17 +2,28 @@ package arkb
 
 import (
 	"encoding/csv"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
-func Collect() (result types.Result) {
+const (
+	// pageURL lists the fund's holdings next to the CSV download
+	pageURL = "https://www.ark-funds.com/funds/arkb"
+
+	rowSelector  = "table tbody tr"
+	holdingMatch = `text "BITCOIN" in td:nth-of-type(1)`
+	sharesColumn = "Shares"
+)
+
+func Collect() (result types.Result, err error) {
 	url := "https://assets.ark-funds.com/fund-documents/funds-etf-csv/ARK_21SHARES_BITCOIN_ETF_ARKB_HOLDINGS.csv"
 
 	// Create a new HTTP client
@@ -21,8 +32,7 @@ func Collect() (result types.Result) {
 	// Create a new GET request
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Println("Error creating request:", err)
-		return
+		return result, fmt.Errorf("creating request: %w", err)
 	}
 
 	// Set headers
@@ -31,16 +41,14 @@ func Collect() (result types.Result) {
 	// Perform the request
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Println("Error performing request:", err)
-		return
+		return result, fmt.Errorf("performing request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("Error reading response body:", err)
-		return
+		return result, fmt.Errorf("reading response body: %w", err)
 	}
 
 	r := csv.NewReader(strings.NewReader(string(body)))
@@ -51,30 +59,69 @@ func Collect() (result types.Result) {
 			break
 		}
 		if err != nil {
-			log.Println(err)
+			return result, fmt.Errorf("reading CSV: %w", err)
 		}
 
 		// CSV record validity check
 		if len(record) < 6 {
-			log.Printf("ARKB: Invalid record length: expected at least 6 fields, got %d", len(record))
-			return
+			return result, fmt.Errorf("invalid record length: expected at least 6 fields, got %d", len(record))
 		}
 
 		if i == 1 {
-			dateRaw := record[0]
-			// Define the layout of the input date
-			layout := "01/02/2006"
-			// Parse the string as a time.Time value
-			parsedTime, _ := time.Parse(layout, dateRaw)
+			date, err := parse.Date("ARKB", "date", record[0])
+			if err != nil {
+				return result, err
+			}
+
+			total, err := parse.Number("ARKB", "holdings", record[5])
+			if err != nil {
+				return result, err
+			}
 
-			totalRaw := record[5]
-			inputClean := strings.ReplaceAll(totalRaw, ",", "")
-			total, _ := strconv.ParseFloat(inputClean, 64)
+			return types.Result{Date: date, TotalAsset: total}, nil
+		}
+	}
+
+	return result, nil
+}
 
-			result = types.Result{Date: parsedTime, TotalAsset: total}
+// CollectPage reads the holdings from the fund page's holdings table,
+// independently of the CSV used by Collect
+func CollectPage() (result types.Result, diag *scrape.Diagnostics, err error) {
+	c := colly.NewCollector()
+	diag = scrape.New(c, pageURL, rowSelector, holdingMatch, sharesColumn)
+
+	c.OnHTML(rowSelector, func(e *colly.HTMLElement) {
+		diag.Match(rowSelector)
+		diag.Candidate()
+		if !strings.Contains(strings.ToUpper(e.ChildText("td:nth-of-type(1)")), "BITCOIN") {
+			return
+		}
+		diag.Match(holdingMatch)
+
+		table := e.DOM.Closest("table")
+		diag.SetFingerprint(scrape.Headers(table), scrape.Structure(e.DOM))
+
+		// The columns are found by their header, the table shows more of them on wider pages
+		column := -1
+		table.Find("thead th").Each(func(i int, th *goquery.Selection) {
+			if strings.TrimSpace(th.Text()) == sharesColumn {
+				column = i
+			}
+		})
+		if column < 0 {
 			return
 		}
+		diag.Match(sharesColumn)
+
+		result.TotalAsset, err = parse.Number("ARKB", "bitcoin shares", e.DOM.Find("td").Eq(column).Text())
+	})
+
+	if visitErr := c.Visit(pageURL); visitErr != nil {
+		return result, diag, visitErr
 	}
 
-	return result
+	c.Wait()
+
+	return result, diag, err
 }
@@ -1,30 +1,41 @@
 package bitb
 
 import (
-	"strconv"
 	"strings"
 
 	"github.com/gocolly/colly/v2"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
-func Collect() (result types.Result) {
+const (
+	url = "https://bitbetf.com"
+
+	sectionSelector = "div[class*='layout-base']"
+	label           = "Bitcoin in Trust"
+	labelMatch      = `text "` + label + `"`
+)
+
+func Collect() (result types.Result, diag *scrape.Diagnostics, err error) {
 	// Create a new collector
 	c := colly.NewCollector()
+	diag = scrape.New(c, url, sectionSelector, labelMatch)
 
 	// Find and visit the target URL
-	c.OnHTML("div[class*='layout-base']", func(e *colly.HTMLElement) {
+	c.OnHTML(sectionSelector, func(e *colly.HTMLElement) {
+		diag.Match(sectionSelector)
 		// Check if the div contains the desired text
-		if strings.Contains(e.Text, "Bitcoin in Trust") {
+		if strings.Contains(e.Text, label) {
 			// Look for the div containing the value
 			e.ForEach("div", func(_ int, el *colly.HTMLElement) {
-				if strings.Contains(el.Text, "Bitcoin in Trust") {
+				diag.Candidate()
+				if strings.Contains(el.Text, label) {
+					diag.Match(labelMatch)
 					// Get the next div element which contains the figure
-					figure := el.DOM.Next().Text()
-					// Print the figure
-					inputClean := strings.ReplaceAll(figure, ",", "")
-					totalBitcoinInTrust, _ := strconv.ParseFloat(inputClean, 64)
-					result.TotalAsset = totalBitcoinInTrust
+					next := el.DOM.Next()
+					diag.SetFingerprint(scrape.Structure(el.DOM), scrape.Structure(next))
+					result.TotalAsset, err = parse.Number("BITB", label, next.Text())
 					return
 				}
 			})
@@ -32,9 +43,11 @@ func Collect() (result types.Result) {
 	})
 
 	// Visit the website
-	c.Visit("https://bitbetf.com")
+	if visitErr := c.Visit(url); visitErr != nil {
+		return result, diag, visitErr
+	}
 
 	c.Wait()
 
-	return result
+	return result, diag, err
 }
@@ -1,34 +1,52 @@
 package brrr
 
 import (
-	"strconv"
 	"strings"
 
 	"github.com/gocolly/colly/v2"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
-func Collect() (result types.Result) {
+const (
+	url = "https://valkyrieinvest.com/brrr-holdings/"
+
+	rowSelector      = "table tbody tr"
+	holdingMatch     = `text "XBTUSD" in td:nth-of-type(1)`
+	quantitySelector = "td:nth-of-type(4)"
+)
+
+func Collect() (result types.Result, diag *scrape.Diagnostics, err error) {
 	// Create a new collector
 	c := colly.NewCollector()
+	diag = scrape.New(c, url, rowSelector, holdingMatch, quantitySelector)
 
 	// Find and visit the target URL
-	c.OnHTML("table tbody tr", func(e *colly.HTMLElement) {
+	c.OnHTML(rowSelector, func(e *colly.HTMLElement) {
+		diag.Match(rowSelector)
+		diag.Candidate()
 		// Check the row and 1st column text
 		if strings.Contains(e.ChildText("td:nth-of-type(1)"), "XBTUSD") {
+			diag.Match(holdingMatch)
+			diag.SetFingerprint(scrape.Headers(e.DOM.Closest("table")), scrape.Structure(e.DOM))
+			if e.DOM.Find(quantitySelector).Length() > 0 {
+				diag.Match(quantitySelector)
+			}
+
 			// Extract
-			totalBitcoinRaw := e.ChildText("td:nth-of-type(4)")
-			inputClean := strings.ReplaceAll(totalBitcoinRaw, ",", "")
-			totalBitcoinInTrust, _ := strconv.ParseFloat(inputClean, 64)
-			result.TotalAsset = totalBitcoinInTrust
+			totalBitcoinRaw := e.ChildText(quantitySelector)
+			result.TotalAsset, err = parse.Number("BRRR", "XBTUSD quantity", totalBitcoinRaw)
 			return
 		}
 	})
 
 	// Visit the website
-	c.Visit("https://valkyrieinvest.com/brrr-holdings/")
+	if visitErr := c.Visit(url); visitErr != nil {
+		return result, diag, visitErr
+	}
 
 	c.Wait()
 
-	return result
+	return result, diag, err
 }
@@ -1,57 +1,91 @@
 package btcw
 
 import (
+	"errors"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
-	"time"
 
 	"github.com/gocolly/colly/v2"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
-func Collect() (result types.Result) {
+const (
+	url = "https://www.wisdomtree.com/investments/global/etf-details/modals/all-current-day-holdings?id={E22BFB6E-98F0-4CAE-AFAE-699175D6F697}"
+
+	scriptSelector = "script"
+	tableMatch     = `text "current-day-holdings-table"`
+	bitcoinMatch   = `text "BITCOIN"`
+	dateMatch      = `pattern "COBDate"`
+	sharesMatch    = `pattern "SharesPar"`
+)
+
+// Define the regular expressions to extract the date and sharespar
+var (
+	dateRegex      = regexp.MustCompile(`"COBDate":"([^"]*)"`)
+	sharesParRegex = regexp.MustCompile(`"SharesPar":"?([^",}]*)`)
+	keyRegex       = regexp.MustCompile(`"(\w+)":`)
+)
+
+func Collect() (result types.Result, diag *scrape.Diagnostics, err error) {
 	// Instantiate a new collector
 	c := colly.NewCollector()
+	diag = scrape.New(c, url, scriptSelector, tableMatch, bitcoinMatch, dateMatch, sharesMatch)
 
-	// Define the regular expressions to extract the date and sharespar
-	dateRegex := regexp.MustCompile(`"COBDate":"(\d{2}/\d{2}/\d{4})"`)
-	sharesParRegex := regexp.MustCompile(`"SharesPar":"(\d+(?:\.\d+)?)`)
-
-	c.OnHTML("script", func(e *colly.HTMLElement) {
+	var errs []error
+	c.OnHTML(scriptSelector, func(e *colly.HTMLElement) {
+		diag.Match(scriptSelector)
+		diag.Candidate()
 		// Check if the script contains the desired JavaScript snippet
 		if strings.Contains(e.Text, "WTree.exporter.addExportedItem('current-day-holdings-table'") {
+			diag.Match(tableMatch)
 			// Extract the JavaScript code containing the data
 			scriptText := e.Text
+			diag.SetFingerprint(keys(scriptText)...)
 
 			// Check if the script contains the desired JavaScript snippet with BITCOIN
 			if strings.Contains(scriptText, "BITCOIN") {
+				diag.Match(bitcoinMatch)
 				// Extract the date using the dateRegex
-				dateMatch := dateRegex.FindStringSubmatch(scriptText)
-				if len(dateMatch) >= 2 {
-					dateRaw := dateMatch[1]
-					// Define the layout of the input date
-					layout := "01/02/2006"
-					// Parse the string as a time.Time value
-					parsedTime, _ := time.Parse(layout, dateRaw)
-					result.Date = parsedTime
+				if match := dateRegex.FindStringSubmatch(scriptText); len(match) >= 2 {
+					diag.Match(dateMatch)
+					date, err := parse.Date("BTCW", "COBDate", match[1])
+					errs = append(errs, err)
+					result.Date = date
 				}
 
 				// Extract the sharespar using the sharesParRegex
-				sharesParMatch := sharesParRegex.FindStringSubmatch(scriptText)
-				if len(sharesParMatch) >= 2 {
-					totalRaw := sharesParMatch[1]
-					inputClean := strings.ReplaceAll(totalRaw, ",", "")
-					total, _ := strconv.ParseFloat(inputClean, 64)
+				if match := sharesParRegex.FindStringSubmatch(scriptText); len(match) >= 2 {
+					diag.Match(sharesMatch)
+					total, err := parse.Number("BTCW", "SharesPar", match[1])
+					errs = append(errs, err)
 					result.TotalAsset = total
 				}
 			}
 		}
 	})
 
-	c.Visit("https://www.wisdomtree.com/investments/global/etf-details/modals/all-current-day-holdings?id={E22BFB6E-98F0-4CAE-AFAE-699175D6F697}")
+	if visitErr := c.Visit(url); visitErr != nil {
+		return result, diag, visitErr
+	}
 
 	c.Wait()
 
-	return result
+	return result, diag, errors.Join(errs...)
+}
+
+// keys returns the distinct JSON keys in the holdings script, its schema
+func keys(script string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, match := range keyRegex.FindAllStringSubmatch(script, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,207 @@
+package funds
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+// Source is one independent collector of a fund's holdings. Collectors
+// reading HTML also return the page structure they saw.
+type Source struct {
+	Name    string
+	Collect func() (types.Result, *scrape.Diagnostics, error)
+}
+
+// Policy decides how the results of several sources are combined
+type Policy string
+
+const (
+	// Primary uses the first source, verified by the others that answered,
+	// and reports those that disagree
+	Primary Policy = "primary"
+	// Majority uses the value more than half of the sources agree on
+	Majority Policy = "majority"
+	// FirstAvailable tries the sources in order and uses the first result
+	FirstAvailable Policy = "first"
+)
+
+var Policies = []Policy{Primary, Majority, FirstAvailable}
+
+// Outcome is the combined result and what each source returned
+type Outcome struct {
+	Result types.Result
+	// Source the result came from, or the agreeing sources for a majority
+	Source  string
+	Results map[string]types.Result
+	// Errors of the sources that failed
+	Errors map[string]error
+	// Diagnostics of the sources that read HTML
+	Diagnostics map[string]*scrape.Diagnostics
+	// Disagreement describes sources that returned different holdings
+	Disagreement string
+}
+
+// Err joins the errors of the failed sources, naming each source when there
+// is more than one
+func (o Outcome) Err() error {
+	if len(o.Errors) == 1 && len(o.Results) == 1 {
+		for _, err := range o.Errors {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(o.Errors))
+	for name := range o.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		errs = append(errs, fmt.Errorf("%s: %w", name, o.Errors[name]))
+	}
+	return errors.Join(errs...)
+}
+
+// record stores a source's result, or its error with an empty result
+func (o *Outcome) record(name string, result types.Result, diag *scrape.Diagnostics, err error) {
+	if diag != nil {
+		o.Diagnostics[name] = diag
+	}
+	if err != nil {
+		o.Errors[name] = err
+		result = types.Result{}
+	}
+	o.Results[name] = result
+}
+
+// Combine collects from the sources and combines them with the policy.
+// Results within tolerance bitcoin of each other agree. A source returning an
+// error or no holdings is treated as failed, not as a disagreement. The primary
+// policy keeps the primary result when the others disagree, while a majority
+// without more than half of the sources has an empty result.
+func Combine(policy Policy, tolerance float64, sources []Source) Outcome {
+	outcome := Outcome{Results: map[string]types.Result{}, Errors: map[string]error{}, Diagnostics: map[string]*scrape.Diagnostics{}}
+
+	switch len(sources) {
+	case 0:
+		return outcome
+	case 1:
+		result, diag, err := sources[0].Collect()
+		outcome.record(sources[0].Name, result, diag, err)
+		outcome.Result, outcome.Source = outcome.Results[sources[0].Name], sources[0].Name
+		return outcome
+	}
+
+	if policy == FirstAvailable {
+		for _, source := range sources {
+			result, diag, err := source.Collect()
+			outcome.record(source.Name, result, diag, err)
+			if result := outcome.Results[source.Name]; result.TotalAsset != 0 {
+				outcome.Result, outcome.Source = result, source.Name
+				break
+			}
+		}
+		return outcome
+	}
+
+	collectAll(&outcome, sources)
+
+	switch policy {
+	case Majority:
+		groups := agreeing(sources, outcome.Results, tolerance)
+		if len(groups) > 1 {
+			outcome.Disagreement = describe(groups, outcome.Results)
+		}
+		if len(groups) > 0 && len(groups[0])*2 > len(sources) {
+			outcome.Result = outcome.Results[groups[0][0]]
+			outcome.Source = strings.Join(groups[0], ",")
+		}
+	default:
+		primary := sources[0].Name
+		outcome.Result, outcome.Source = outcome.Results[primary], primary
+
+		var differ []string
+		for _, source := range sources[1:] {
+			result := outcome.Results[source.Name]
+			if result.TotalAsset != 0 && outcome.Result.TotalAsset != 0 && math.Abs(result.TotalAsset-outcome.Result.TotalAsset) > tolerance {
+				differ = append(differ, source.Name)
+			}
+		}
+		if len(differ) > 0 {
+			outcome.Disagreement = describe([][]string{append([]string{primary}, differ...)}, outcome.Results)
+		}
+	}
+
+	return outcome
+}
+
+// collectAll runs every source in parallel
+func collectAll(outcome *Outcome, sources []Source) {
+	results := make([]types.Result, len(sources))
+	diags := make([]*scrape.Diagnostics, len(sources))
+	errs := make([]error, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			results[i], diags[i], errs[i] = source.Collect()
+		}(i, source)
+	}
+	wg.Wait()
+
+	for i, source := range sources {
+		outcome.record(source.Name, results[i], diags[i], errs[i])
+	}
+}
+
+// agreeing groups the sources that returned holdings by value, largest group first
+func agreeing(sources []Source, results map[string]types.Result, tolerance float64) [][]string {
+	var groups [][]string
+	for _, source := range sources {
+		result := results[source.Name]
+		if result.TotalAsset == 0 {
+			continue
+		}
+
+		placed := false
+		for i, group := range groups {
+			if math.Abs(results[group[0]].TotalAsset-result.TotalAsset) <= tolerance {
+				groups[i] = append(group, source.Name)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			groups = append(groups, []string{source.Name})
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i]) > len(groups[j])
+	})
+	return groups
+}
+
+// describe lists what each named source returned
+func describe(groups [][]string, results map[string]types.Result) string {
+	var parts []string
+	for _, group := range groups {
+		for _, name := range group {
+			part := fmt.Sprintf("%s %.2f", name, results[name].TotalAsset)
+			if date := results[name].Date; !date.IsZero() {
+				part += " on " + date.Format("2006-01-02")
+			}
+			parts = append(parts, part)
+		}
+	}
+	return "sources disagree: " + strings.Join(parts, ", ")
+}
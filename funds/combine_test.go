@@ -0,0 +1,205 @@
+package funds
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestCombine(t *testing.T) {
+	source := func(name string, total float64) Source {
+		return Source{Name: name, Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{TotalAsset: total}, nil, nil }}
+	}
+	failing := func(name string) Source {
+		return Source{Name: name, Collect: func() (types.Result, *scrape.Diagnostics, error) {
+			return types.Result{TotalAsset: 99}, nil, errors.New("page changed")
+		}}
+	}
+
+	tests := []struct {
+		name         string
+		policy       Policy
+		sources      []Source
+		wantTotal    float64
+		wantSource   string
+		wantDisagree string
+	}{
+		{name: "single source", policy: Majority, sources: []Source{source("a", 100)}, wantTotal: 100, wantSource: "a"},
+		{name: "primary verified", policy: Primary, sources: []Source{source("a", 100), source("b", 100.5)}, wantTotal: 100, wantSource: "a"},
+		{name: "primary with failed verifier", policy: Primary, sources: []Source{source("a", 100), source("b", 0)}, wantTotal: 100, wantSource: "a"},
+		{name: "primary with erroring verifier", policy: Primary, sources: []Source{source("a", 100), failing("b")}, wantTotal: 100, wantSource: "a"},
+		{name: "single source error", policy: Primary, sources: []Source{failing("a")}, wantSource: "a"},
+		{name: "primary disputed", policy: Primary, sources: []Source{source("a", 100), source("b", 120)}, wantTotal: 100, wantSource: "a", wantDisagree: "a 100.00, b 120.00"},
+		{name: "majority", policy: Majority, sources: []Source{source("a", 120), source("b", 100), source("c", 100.2)}, wantTotal: 100, wantSource: "b,c", wantDisagree: "b 100.00, c 100.20, a 120.00"},
+		{name: "majority tie", policy: Majority, sources: []Source{source("a", 100), source("b", 120)}, wantDisagree: "a 100.00, b 120.00"},
+		{name: "majority of failed sources", policy: Majority, sources: []Source{source("a", 100), source("b", 0), source("c", 0)}},
+		{name: "first available", policy: FirstAvailable, sources: []Source{failing("a"), source("b", 100), source("c", 120)}, wantTotal: 100, wantSource: "b"},
+		{name: "none available", policy: FirstAvailable, sources: []Source{source("a", 0), source("b", 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := Combine(tt.policy, 1, tt.sources)
+			if outcome.Result.TotalAsset != tt.wantTotal || outcome.Source != tt.wantSource {
+				t.Errorf("Combine() = %v from %q, want %v from %q", outcome.Result.TotalAsset, outcome.Source, tt.wantTotal, tt.wantSource)
+			}
+			if (tt.wantDisagree == "") != (outcome.Disagreement == "") || !strings.Contains(outcome.Disagreement, tt.wantDisagree) {
+				t.Errorf("Disagreement = %q, want %q", outcome.Disagreement, tt.wantDisagree)
+			}
+		})
+	}
+}
+
+func TestCombine_FirstAvailableStops(t *testing.T) {
+	called := false
+	sources := []Source{
+		{Name: "a", Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{TotalAsset: 100}, nil, nil }},
+		{Name: "b", Collect: func() (types.Result, *scrape.Diagnostics, error) { called = true; return types.Result{}, nil, nil }},
+	}
+
+	Combine(FirstAvailable, 1, sources)
+	if called {
+		t.Error("second source collected after the first answered")
+	}
+}
+
+func TestOutcome_Err(t *testing.T) {
+	single := Combine(Primary, 1, []Source{
+		{Name: "a", Collect: func() (types.Result, *scrape.Diagnostics, error) {
+			return types.Result{}, nil, errors.New("page changed")
+		}},
+	})
+	if err := single.Err(); err == nil || err.Error() != "page changed" {
+		t.Errorf("single source Err() = %v", err)
+	}
+
+	several := Combine(Majority, 1, []Source{
+		{Name: "b", Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{}, nil, errors.New("timeout") }},
+		{Name: "a", Collect: func() (types.Result, *scrape.Diagnostics, error) {
+			return types.Result{}, nil, errors.New("page changed")
+		}},
+		{Name: "c", Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{TotalAsset: 1}, nil, nil }},
+	})
+	if err := several.Err(); err == nil || err.Error() != "a: page changed\nb: timeout" {
+		t.Errorf("Err() = %q", err)
+	}
+
+	if err := Combine(Primary, 1, nil).Err(); err != nil {
+		t.Errorf("no sources Err() = %v", err)
+	}
+}
+
+// fixtureTransport serves canned responses in place of the issuer sites
+type fixtureTransport map[string]string
+
+func (f fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := f[req.URL.Host+req.URL.Path]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{"Content-Type": {"text/html"}},
+		Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestCombine_FundSources(t *testing.T) {
+	ibitJSON := `{"aaData": [["BTC", "BITCOIN", "-", "Cryptocurrency", {}, {}, {"display": "195,985.24", "raw": 195985.24}]]}`
+	ibitCSV := func(quantity string) string {
+		return "iShares Bitcoin Trust ETF\nFund Holdings as of,\"Mar 01, 2024\"\nInception Date,\"Jan 11, 2024\"\n \n" +
+			"Ticker,Name,Sector,Asset Class,Market Value,Weight (%),Notional Value,Quantity,Price\n" +
+			"BTC,BITCOIN,-,Cryptocurrency,\"12,345,678,901.00\",99.99,\"12,345,678,901.00\",\"" + quantity + "\",\"62,993.00\"\n" +
+			"USD,USD CASH,Cash and/or Derivatives,Cash,\"1,000.00\",0.01,\"1,000.00\",\"1,000.00\",100.00\n"
+	}
+	arkbCSV := "date,fund,company,ticker,cusip,shares,market value ($),weight (%)\n03/01/2024,ARKB,BITCOIN,BTC,,\"44,951.28\",\"$2,824,135,234.50\",100.00%\n"
+	arkbPage := func(shares string) string {
+		return `<html><body><table><thead><tr><th>Company</th><th>Ticker</th><th>Shares</th><th>Market Value</th></tr></thead>
+			<tbody><tr><td>Bitcoin</td><td>BTC</td><td>` + shares + `</td><td>$2,824,135,234.50</td></tr></tbody></table></body></html>`
+	}
+
+	tests := []struct {
+		name         string
+		fixtures     fixtureTransport
+		sources      []Source
+		wantTotal    float64
+		wantDisagree bool
+		// Sources that return holdings
+		wantAnswered int
+	}{
+		{
+			name: "IBIT sources agree",
+			fixtures: fixtureTransport{
+				"blackrock.com/us/financial-professionals/products/333011/fund/1500962885783.ajax": ibitJSON,
+				"www.ishares.com/us/products/333011/ishares-bitcoin-trust/1467271812596.ajax":      ibitCSV("195,985.24"),
+			},
+			sources:      []Source{{Name: "ajax-json", Collect: IbitCollect}, {Name: "holdings-csv", Collect: IbitCSVCollect}},
+			wantTotal:    195985.24,
+			wantAnswered: 2,
+		},
+		{
+			name: "IBIT CSV disagrees",
+			fixtures: fixtureTransport{
+				"blackrock.com/us/financial-professionals/products/333011/fund/1500962885783.ajax": ibitJSON,
+				"www.ishares.com/us/products/333011/ishares-bitcoin-trust/1467271812596.ajax":      ibitCSV("19,598.52"),
+			},
+			sources:      []Source{{Name: "ajax-json", Collect: IbitCollect}, {Name: "holdings-csv", Collect: IbitCSVCollect}},
+			wantTotal:    195985.24,
+			wantDisagree: true,
+			wantAnswered: 2,
+		},
+		{
+			name: "ARKB sources agree",
+			fixtures: fixtureTransport{
+				"assets.ark-funds.com/fund-documents/funds-etf-csv/ARK_21SHARES_BITCOIN_ETF_ARKB_HOLDINGS.csv": arkbCSV,
+				"www.ark-funds.com/funds/arkb": arkbPage("44,951.28"),
+			},
+			sources:      []Source{{Name: "holdings-csv", Collect: ArkbCollect}, {Name: "fund-page", Collect: ArkbPageCollect}},
+			wantTotal:    44951.28,
+			wantAnswered: 2,
+		},
+		{
+			name: "ARKB page unavailable",
+			fixtures: fixtureTransport{
+				"assets.ark-funds.com/fund-documents/funds-etf-csv/ARK_21SHARES_BITCOIN_ETF_ARKB_HOLDINGS.csv": arkbCSV,
+			},
+			sources:      []Source{{Name: "holdings-csv", Collect: ArkbCollect}, {Name: "fund-page", Collect: ArkbPageCollect}},
+			wantTotal:    44951.28,
+			wantAnswered: 1,
+		},
+		{
+			name: "ARKB page disagrees",
+			fixtures: fixtureTransport{
+				"assets.ark-funds.com/fund-documents/funds-etf-csv/ARK_21SHARES_BITCOIN_ETF_ARKB_HOLDINGS.csv": arkbCSV,
+				"www.ark-funds.com/funds/arkb": arkbPage("4,495.13"),
+			},
+			sources:      []Source{{Name: "holdings-csv", Collect: ArkbCollect}, {Name: "fund-page", Collect: ArkbPageCollect}},
+			wantTotal:    44951.28,
+			wantDisagree: true,
+			wantAnswered: 2,
+		},
+	}
+	previous := http.DefaultTransport
+	defer func() { http.DefaultTransport = previous }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			http.DefaultTransport = tt.fixtures
+
+			outcome := Combine(Primary, 1, tt.sources)
+			if outcome.Result.TotalAsset != tt.wantTotal || (outcome.Disagreement != "") != tt.wantDisagree {
+				t.Errorf("Combine() = %v, disagreement %q, errors %v", outcome.Result.TotalAsset, outcome.Disagreement, outcome.Errors)
+			}
+			answered := 0
+			for _, result := range outcome.Results {
+				if result.TotalAsset != 0 {
+					answered++
+				}
+			}
+			if answered != tt.wantAnswered {
+				t.Errorf("%d sources answered, want %d: %+v, errors %v", answered, tt.wantAnswered, outcome.Results, outcome.Errors)
+			}
+		})
+	}
+}
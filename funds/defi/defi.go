@@ -1,37 +1,51 @@
 package defi
 
 import (
-	"strconv"
 	"strings"
 
 	"github.com/gocolly/colly/v2"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
-func Collect() (result types.Result) {
-	url := "https://hashdex-etfs.com/defi"
+const (
+	url = "https://hashdex-etfs.com/defi"
 
+	tableSelector = "table.table-holdings"
+	holdingMatch  = `text "BITCOIN" in tr`
+	cellSelector  = "td.shares-holding"
+)
+
+func Collect() (result types.Result, diag *scrape.Diagnostics, err error) {
 	c := colly.NewCollector()
+	diag = scrape.New(c, url, tableSelector, holdingMatch, cellSelector)
 
 	// Find and visit the table
-	c.OnHTML("table.table-holdings", func(e *colly.HTMLElement) {
+	c.OnHTML(tableSelector, func(e *colly.HTMLElement) {
+		diag.Match(tableSelector)
 		// Iterate over each row in the table
 		e.ForEach("tr", func(_ int, row *colly.HTMLElement) {
+			diag.Candidate()
 			// Check if the row contains the target value
 			if strings.Contains(row.Text, "BITCOIN") {
-				// Find the cell containing the value
-				totalBitcoinInTrustRaw := row.ChildText("td.shares-holding")
-				inputClean := strings.ReplaceAll(totalBitcoinInTrustRaw, ",", "")
-				inputClean = strings.TrimSpace(inputClean)
-				totalBitcoinInTrust, _ := strconv.ParseFloat(inputClean, 64)
+				diag.Match(holdingMatch)
+				diag.SetFingerprint(scrape.Headers(e.DOM), scrape.Structure(row.DOM))
+				if row.DOM.Find(cellSelector).Length() > 0 {
+					diag.Match(cellSelector)
+				}
 
-				result.TotalAsset = totalBitcoinInTrust
+				// Find the cell containing the value
+				totalBitcoinInTrustRaw := row.ChildText(cellSelector)
+				result.TotalAsset, err = parse.Number("DEFI", "shares holding", totalBitcoinInTrustRaw)
 			}
 		})
 	})
 
 	// Visit the URL
-	c.Visit(url)
+	if visitErr := c.Visit(url); visitErr != nil {
+		return result, diag, visitErr
+	}
 
-	return result
+	return result, diag, err
 }
@@ -3,13 +3,11 @@ package ezbc
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"strconv"
-	"strings"
-	"time"
 
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
@@ -35,7 +33,7 @@ type Holding struct {
 	Quantity string `json:"quantityshrpar"`
 }
 
-func Collect() (result types.Result) {
+func Collect() (result types.Result, err error) {
 	// This API key is hard coded on their web site
 	url := "https://www.franklintempleton.com/api/pds/price-and-performance?apikey=4ef35821-5244-41bc-a699-0192d002c3d1p&op=Holdings&id=14"
 
@@ -56,48 +54,44 @@ func Collect() (result types.Result) {
 	// Create a new GET request
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
 	if err != nil {
-		log.Println("Error creating request:", err)
-		return
+		return result, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	// Perform the request
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Println("Error performing request:", err)
-		return
+		return result, fmt.Errorf("performing request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("Error reading response body:", err)
-		return
+		return result, fmt.Errorf("reading response body: %w", err)
 	}
 
 	var data FundData
 	if err := json.Unmarshal([]byte(body), &data); err != nil {
-		log.Printf("Error unmarshalling JSON: %v", err)
+		return result, fmt.Errorf("unmarshalling JSON: %w", err)
 	}
 
 	// Iterate
 	for _, nav := range data.Data.Portfolio.PortfolioData.DailyHoldings {
 		if nav.SECName == "BITCOIN" {
 			// Extract
-			totalRaw := nav.Quantity
-			inputClean := strings.ReplaceAll(totalRaw, ",", "")
-			total, _ := strconv.ParseFloat(inputClean, 64)
-			result.TotalAsset = total
-
-			// Define the layout of the input date
-			layout := "01/02/2006"
-			// Parse the string as a time.Time value
-			parsedTime, _ := time.Parse(layout, nav.Date)
-			result.Date = parsedTime
-			return
+			total, err := parse.Number("EZBC", "quantityshrpar", nav.Quantity)
+			if err != nil {
+				return result, err
+			}
+			date, err := parse.Date("EZBC", "asofdate", nav.Date)
+			if err != nil {
+				return result, err
+			}
+
+			return types.Result{TotalAsset: total, Date: date}, nil
 		}
 	}
 
-	return result
+	return result, nil
 }
@@ -4,36 +4,40 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gocolly/colly/v2"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 
 	"github.com/ledongthuc/pdf"
 )
 
-func Collect() (result types.Result) {
-	actionExchangeRepositoryURL := getActionExchangeRepositoryURL()
-	collectionID := getCollectionID(actionExchangeRepositoryURL)
+func Collect() (result types.Result, err error) {
+	actionExchangeRepositoryURL, err := getActionExchangeRepositoryURL()
+	if err != nil {
+		return result, err
+	}
+	collectionID, err := getCollectionID(actionExchangeRepositoryURL)
+	if err != nil {
+		return result, err
+	}
 
 	url := fmt.Sprintf("https://www.actionsxchangerepository.fidelity.com/ShowDocument/documentPDF.htm?clientId=Fidelity&applicationId=MFL&securityId=315948109&docType=DALY&docFormat=pdf&securityIdType=CUSIP&collectionId=%d&docName=1.WOB-DALY.pdf&criticalIndicator=N&pdfReaderStatus=Y", collectionID)
 
 	// Fetch the data from the URL
 	resp, err := http.Get(url)
 	if err != nil {
-		log.Println("Error performing request:", err)
-		return
+		return result, fmt.Errorf("performing request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("Error reading response body:", err)
-		return
+		return result, fmt.Errorf("reading response body: %w", err)
 	}
 
 	// Create a reader from the byte slice
@@ -41,30 +45,41 @@ func Collect() (result types.Result) {
 
 	r, err := pdf.NewReader(reader, int64(len(body)))
 	if err != nil {
-		log.Println("Error creating reader:", err)
-		return
+		return result, fmt.Errorf("creating reader: %w", err)
 	}
 
 	pageIndex := 1
 	p := r.Page(pageIndex)
 
-	rows, _ := p.GetTextByRow()
+	rows, err := p.GetTextByRow()
+	if err != nil {
+		return result, fmt.Errorf("reading rows: %w", err)
+	}
 	// hard code retrieve
+	if len(rows) < 1 || len(rows[0].Content) < 10 {
+		return result, fmt.Errorf("holdings row not found in the daily PDF")
+	}
 	rowHoldings := rows[0].Content[9].S
 
-	cols, _ := p.GetTextByColumn()
+	cols, err := p.GetTextByColumn()
+	if err != nil {
+		return result, fmt.Errorf("reading columns: %w", err)
+	}
 	// hard code retrieve
+	if len(cols) < 2 || len(cols[1].Content) < 24 {
+		return result, fmt.Errorf("holdings column not found in the daily PDF")
+	}
 	colHoldings := cols[1].Content[23].S
 
-	if rowHoldings == colHoldings {
-		total, _ := strconv.ParseFloat(rowHoldings, 64)
-		result.TotalAsset = total
+	if rowHoldings != colHoldings {
+		return result, fmt.Errorf("holdings row %q and column %q differ", rowHoldings, colHoldings)
 	}
 
-	return
+	result.TotalAsset, err = parse.Number("FBTC", "holdings", rowHoldings)
+	return result, err
 }
 
-func getActionExchangeRepositoryURL() (redirectURL string) {
+func getActionExchangeRepositoryURL() (redirectURL string, err error) {
 	// This static URL redirects to www.actionsxchangerepository.fidelity.com
 	url := "https://fundresearch.fidelity.com/prospectus/eproredirect?clientId=Fidelity&applicationId=MFL&securityIdType=CUSIP&critical=N&securityId=315948109"
 
@@ -76,44 +91,44 @@ func getActionExchangeRepositoryURL() (redirectURL string) {
 	})
 
 	// Visit the URL
-	err := c.Visit(url)
-	if err != nil {
-		log.Println("Error:", err)
-		return ""
+	if err := c.Visit(url); err != nil {
+		return "", err
 	}
 
-	return redirectURL
+	return redirectURL, nil
 }
 
-func getCollectionID(url string) (collectionID int) {
+func getCollectionID(url string) (collectionID int, err error) {
 	c := colly.NewCollector()
 
 	c.OnHTML("td", func(e *colly.HTMLElement) {
 		// Check if the fundDocumentType is "DALY"
 		if strings.Contains(e.Attr("onclick"), "'DALY'") {
 			// Extract
-			collectionID = extractCollectionIDFromOnClick(e.Attr("onclick"))
+			collectionID, err = extractCollectionIDFromOnClick(e.Attr("onclick"))
 		}
 	})
 
 	// Visit the URL
-	err := c.Visit(url)
-	if err != nil {
-		log.Println("Error:", err)
-		return 0
+	if visitErr := c.Visit(url); visitErr != nil {
+		return 0, visitErr
 	}
 
-	return collectionID
+	return collectionID, err
 }
 
-func extractCollectionIDFromOnClick(onClick string) int {
+func extractCollectionIDFromOnClick(onClick string) (int, error) {
 	// Split the onClick attribute by comma and extract the element
 	parts := strings.Split(onClick, ",")
-	if len(parts) >= 5 {
-		// Remove surrounding quotes and trim whitespace
-		rawID := strings.TrimSpace(strings.Trim(parts[6], "'"))
-		ID, _ := strconv.Atoi(rawID)
-		return ID
+	if len(parts) < 7 {
+		return 0, &parse.Error{Fund: "FBTC", Field: "collection ID", Raw: onClick, Err: parse.ErrSyntax}
+	}
+
+	// Remove surrounding quotes and trim whitespace
+	rawID := strings.TrimSpace(strings.Trim(strings.TrimSpace(parts[6]), "'"))
+	ID, err := strconv.Atoi(rawID)
+	if err != nil {
+		return 0, &parse.Error{Fund: "FBTC", Field: "collection ID", Raw: rawID, Err: parse.ErrSyntax}
 	}
-	return 0
+	return ID, nil
 }
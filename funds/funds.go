@@ -11,45 +11,59 @@ import (
 	"github.com/jyap808/btcEtfScrape/funds/gbtc"
 	"github.com/jyap808/btcEtfScrape/funds/hodl"
 	"github.com/jyap808/btcEtfScrape/funds/ibit"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
-func ArkbCollect() types.Result {
-	return arkb.Collect()
+func ArkbCollect() (types.Result, *scrape.Diagnostics, error) {
+	return withoutDiagnostics(arkb.Collect())
 }
 
-func BitbCollect() types.Result {
+func ArkbPageCollect() (types.Result, *scrape.Diagnostics, error) {
+	return arkb.CollectPage()
+}
+
+func BitbCollect() (types.Result, *scrape.Diagnostics, error) {
 	return bitb.Collect()
 }
 
-func BrrrCollect() types.Result {
+func BrrrCollect() (types.Result, *scrape.Diagnostics, error) {
 	return brrr.Collect()
 }
 
-func BtcwCollect() types.Result {
+func BtcwCollect() (types.Result, *scrape.Diagnostics, error) {
 	return btcw.Collect()
 }
 
-func DefiCollect() types.Result {
+func DefiCollect() (types.Result, *scrape.Diagnostics, error) {
 	return defi.Collect()
 }
 
-func EzbcCollect() types.Result {
-	return ezbc.Collect()
+func EzbcCollect() (types.Result, *scrape.Diagnostics, error) {
+	return withoutDiagnostics(ezbc.Collect())
+}
+
+func FbtcCollect() (types.Result, *scrape.Diagnostics, error) {
+	return withoutDiagnostics(fbtc.Collect())
+}
+
+func GbtcCollect() (types.Result, *scrape.Diagnostics, error) {
+	return withoutDiagnostics(gbtc.Collect())
 }
 
-func FbtcCollect() types.Result {
-	return fbtc.Collect()
+func HodlCollect() (types.Result, *scrape.Diagnostics, error) {
+	return withoutDiagnostics(hodl.Collect())
 }
 
-func GbtcCollect() types.Result {
-	return gbtc.Collect()
+func IbitCollect() (types.Result, *scrape.Diagnostics, error) {
+	return withoutDiagnostics(ibit.Collect())
 }
 
-func HodlCollect() types.Result {
-	return hodl.Collect()
+func IbitCSVCollect() (types.Result, *scrape.Diagnostics, error) {
+	return withoutDiagnostics(ibit.CollectCSV())
 }
 
-func IbitCollect() types.Result {
-	return ibit.Collect()
+// withoutDiagnostics adapts a collector that does not read HTML
+func withoutDiagnostics(result types.Result, err error) (types.Result, *scrape.Diagnostics, error) {
+	return result, nil, err
 }
@@ -3,12 +3,10 @@ package gbtc
 import (
 	"encoding/json"
 	"fmt"
-	"log"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gocolly/colly/v2"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
@@ -22,7 +20,7 @@ type nextData struct {
 	}
 }
 
-func Collect() (result types.Result) {
+func Collect() (result types.Result, err error) {
 	// creating a new Colly instance
 	c := colly.NewCollector()
 
@@ -33,9 +31,9 @@ func Collect() (result types.Result) {
 
 		// Parse the content as JSON
 		var data nextData
-		err := json.NewDecoder(strings.NewReader(nextDataContent)).Decode(&data)
-		if err != nil {
-			log.Println(err)
+		if err = json.NewDecoder(strings.NewReader(nextDataContent)).Decode(&data); err != nil {
+			err = fmt.Errorf("decoding __NEXT_DATA__: %w", err)
+			return
 		}
 
 		// Access the "includes" field
@@ -43,17 +41,16 @@ func Collect() (result types.Result) {
 
 		// Search for the value within "includes"
 		result, err = findResultsInIncludes(includesData)
-		if err != nil {
-			log.Println(err)
-		}
 	})
 
 	// visiting the target page
-	c.Visit("https://etfs.grayscale.com/gbtc")
+	if visitErr := c.Visit("https://etfs.grayscale.com/gbtc"); visitErr != nil {
+		return result, visitErr
+	}
 
 	c.Wait()
 
-	return result
+	return result, err
 }
 
 // findResultsInIncludes searches for the unique field within "includes"
@@ -70,17 +67,39 @@ func findResultsInIncludes(includesData map[string]interface{}) (types.Result, e
 		// Search for "totalAssetInTrustRaw" within each include
 		totalAssetInTrustRaw, found := include["totalAssetInTrust"].(string)
 		if found {
-			inputClean := strings.ReplaceAll(totalAssetInTrustRaw, ",", "")
-			totalAssetInTrust, _ := strconv.ParseFloat(inputClean, 64)
+			totalAssetInTrust, err := parse.Number("GBTC", "totalAssetInTrust", totalAssetInTrustRaw)
+			if err != nil {
+				return types.Result{}, err
+			}
 
-			// Define the layout of the input date
-			layout := "01/02/2006"
-			// Parse the string as a time.Time value
-			parsedTime, _ := time.Parse(layout, include["date"].(string))
+			// The date sits beside the total and may be missing or not a string
+			dateRaw, ok := include["date"].(string)
+			if !ok {
+				return types.Result{}, &parse.Error{Fund: "GBTC", Field: "date", Raw: fmt.Sprint(include["date"]), Err: parse.ErrDate}
+			}
+			parsedTime, err := parse.Date("GBTC", "date", dateRaw)
+			if err != nil {
+				return types.Result{}, err
+			}
 
 			result.TotalAsset = totalAssetInTrust
 			result.Date = parsedTime
 
+			// Shares outstanding and bitcoin per share reconcile the total when published
+			optional := map[string]*float64{
+				"sharesOutstanding": &result.SharesOutstanding,
+				"bitcoinPerShare":   &result.BitcoinPerShare,
+			}
+			for field, target := range optional {
+				raw, ok := include[field].(string)
+				if !ok {
+					continue
+				}
+				if *target, err = parse.Number("GBTC", field, raw); err != nil {
+					return types.Result{}, err
+				}
+			}
+
 			return *result, nil
 		}
 	}
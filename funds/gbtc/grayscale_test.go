@@ -0,0 +1,43 @@
+package gbtc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/parse"
+)
+
+func TestFindResultsInIncludes(t *testing.T) {
+	result, err := findResultsInIncludes(map[string]interface{}{
+		"other": "ignored",
+		"fund": map[string]interface{}{"totalAssetInTrust": "285,432.1234", "date": "03/01/2024",
+			"sharesOutstanding": "325,140,100", "bitcoinPerShare": "0.00087787"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalAsset != 285432.1234 || !result.Date.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) ||
+		result.SharesOutstanding != 325140100 || result.BitcoinPerShare != 0.00087787 {
+		t.Errorf("result = %+v", result)
+	}
+
+	tests := []struct {
+		name    string
+		include map[string]interface{}
+		wantErr error
+	}{
+		{name: "missing date", include: map[string]interface{}{"totalAssetInTrust": "1,000"}, wantErr: parse.ErrDate},
+		{name: "date not a string", include: map[string]interface{}{"totalAssetInTrust": "1,000", "date": 20240301.0}, wantErr: parse.ErrDate},
+		{name: "bad total", include: map[string]interface{}{"totalAssetInTrust": "N/A", "date": "03/01/2024"}, wantErr: parse.ErrSyntax},
+		{name: "bad bitcoin per share", include: map[string]interface{}{"totalAssetInTrust": "1,000", "date": "03/01/2024", "bitcoinPerShare": "--"}, wantErr: parse.ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := findResultsInIncludes(map[string]interface{}{"fund": tt.include})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,16 +2,19 @@ package hodl
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
+// holdingsLabel is the label of the bitcoin held, the only required field
+const holdingsLabel = "bitcoin in trust"
+
 type FundData struct {
 	Data Data `json:"data"`
 }
@@ -26,7 +29,7 @@ type Nav struct {
 	Value string
 }
 
-func Collect() (result types.Result) {
+func Collect() (result types.Result, err error) {
 	url := "https://www.vaneck.com/Main/NavInformationBlock/GetContent/?blockid=252190&ticker=HODL"
 
 	// NOTE: Fix for getting old cached responses from this endpoint
@@ -39,47 +42,77 @@ func Collect() (result types.Result) {
 	// Create a new GET request
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Println("Error creating request:", err)
-		return
+		return result, fmt.Errorf("creating request: %w", err)
 	}
 
 	// Perform the request
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Println("Error performing request:", err)
-		return
+		return result, fmt.Errorf("performing request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("Error reading response body:", err)
-		return
+		return result, fmt.Errorf("reading response body: %w", err)
 	}
 
 	var data FundData
 	if err := json.Unmarshal([]byte(body), &data); err != nil {
-		log.Printf("Error unmarshalling JSON: %v", err)
+		return result, fmt.Errorf("unmarshalling JSON: %w", err)
+	}
+
+	return parseNavs(data.Data)
+}
+
+// parseNavs reads the holdings and fund data from the NAV block. Labels are
+// matched ignoring case, spacing and a trailing colon or footnote mark.
+func parseNavs(data Data) (types.Result, error) {
+	var result types.Result
+	date, err := parse.Date("HODL", "AsOfDate", data.Date)
+	if err != nil {
+		return result, err
 	}
+	result.Date = date
 
-	// Define the layout of the input date
-	layout := "01/02/2006"
-	// Parse the string as a time.Time value
-	parsedTime, _ := time.Parse(layout, data.Data.Date)
-	result.Date = parsedTime
-
-	// Iterate
-	for _, nav := range data.Data.Navs {
-		if nav.Key == "Bitcoin in Trust" {
-			// Extract
-			totalRaw := nav.Value
-			inputClean := strings.ReplaceAll(totalRaw, ",", "")
-			total, _ := strconv.ParseFloat(inputClean, 64)
-			result.TotalAsset = total
-			return
+	fields := map[string]*float64{
+		holdingsLabel:        &result.TotalAsset,
+		"nav":                &result.NAV,
+		"nav per share":      &result.NAV,
+		"shares outstanding": &result.SharesOutstanding,
+		"bitcoin per share":  &result.BitcoinPerShare,
+	}
+	found := false
+	for _, nav := range data.Navs {
+		key := label(nav.Key)
+		target, ok := fields[key]
+		if !ok {
+			continue
+		}
+		// Extract
+		value, err := parse.Number("HODL", nav.Key, nav.Value)
+		if key == holdingsLabel {
+			if err != nil {
+				return types.Result{}, err
+			}
+			found = true
+		} else if err != nil {
+			// The other fields are optional, the holdings stand without them
+			log.Printf("HODL %v, ignored", err)
+			continue
 		}
+		*target = value
+	}
+	if !found {
+		return types.Result{}, &parse.Error{Fund: "HODL", Field: "Bitcoin in Trust", Err: parse.ErrMissing}
 	}
 
-	return result
+	return result, nil
+}
+
+// label normalizes a NAV block label for matching
+func label(key string) string {
+	key = strings.ToLower(strings.Join(strings.Fields(key), " "))
+	return strings.TrimSpace(strings.TrimRight(key, ":*"))
 }
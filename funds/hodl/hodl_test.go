@@ -0,0 +1,78 @@
+package hodl
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/parse"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestParseNavs(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		body    string
+		want    types.Result
+		wantErr error
+	}{
+		{
+			name: "nav block",
+			body: `{"data": {"AsOfDate": "03/01/2024", "Navs": [
+				{"Key": "NAV", "Value": "$86.12"},
+				{"Key": "Bitcoin in Trust", "Value": "8,123.4567"},
+				{"Key": "Shares Outstanding", "Value": "5,850,000"},
+				{"Key": "Bitcoin per Share", "Value": "0.00138862"},
+				{"Key": "NAV Change", "Value": "-1.25"}]}}`,
+			want: types.Result{Date: date, TotalAsset: 8123.4567, NAV: 86.12, SharesOutstanding: 5850000, BitcoinPerShare: 0.00138862},
+		},
+		{
+			name: "label variants",
+			body: `{"data": {"AsOfDate": "3/1/2024", "Navs": [
+				{"Key": "NAV per Share:", "Value": "86.12"},
+				{"Key": " bitcoin  in trust*", "Value": "8,123.4567"},
+				{"Key": "SHARES OUTSTANDING", "Value": "5,850,000"}]}}`,
+			want: types.Result{Date: date, TotalAsset: 8123.4567, NAV: 86.12, SharesOutstanding: 5850000},
+		},
+		{
+			name: "bad optional value",
+			body: `{"data": {"AsOfDate": "03/01/2024", "Navs": [
+				{"Key": "Bitcoin in Trust", "Value": "8,123.4567"},
+				{"Key": "NAV", "Value": "86.12"},
+				{"Key": "Shares Outstanding", "Value": "N/A"}]}}`,
+			want: types.Result{Date: date, TotalAsset: 8123.4567, NAV: 86.12},
+		},
+		{
+			name:    "bad holdings",
+			body:    `{"data": {"AsOfDate": "03/01/2024", "Navs": [{"Key": "Bitcoin in Trust", "Value": "N/A"}, {"Key": "NAV", "Value": "86.12"}]}}`,
+			wantErr: parse.ErrSyntax,
+		},
+		{
+			name:    "missing holdings",
+			body:    `{"data": {"AsOfDate": "03/01/2024", "Navs": [{"Key": "NAV", "Value": "86.12"}]}}`,
+			wantErr: parse.ErrMissing,
+		},
+		{
+			name:    "missing date",
+			body:    `{"data": {"Navs": [{"Key": "Bitcoin in Trust", "Value": "8,123.4567"}]}}`,
+			wantErr: parse.ErrEmpty,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data FundData
+			if err := json.Unmarshal([]byte(tt.body), &data); err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseNavs(data.Data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseNavs() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("parseNavs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,29 @@
 package ibit
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
 
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jyap808/btcEtfScrape/parse"
 	"github.com/jyap808/btcEtfScrape/types"
 )
 
+const (
+	// productURL shows the fund's NAV and shares outstanding
+	productURL = "https://www.ishares.com/us/products/333011/ishares-bitcoin-trust"
+	// holdingsCSVURL is the holdings download of the product page
+	holdingsCSVURL = productURL + "/1467271812596.ajax?fileType=csv&fileName=IBIT_holdings&dataType=fund"
+
+	navSelector    = ".navAmount .header-nav-data"
+	sharesSelector = ".col-sharesOutstanding .data"
+)
+
 type FundData struct {
 	AaData [][]interface{} `json:"aaData"`
 }
@@ -19,7 +33,7 @@ type Fund struct {
 	Shares types.Result
 }
 
-func Collect() (result types.Result) {
+func Collect() (result types.Result, err error) {
 	url := "https://blackrock.com/us/financial-professionals/products/333011/fund/1500962885783.ajax?tab=all&fileType=json"
 
 	// Create a new HTTP client
@@ -28,25 +42,37 @@ func Collect() (result types.Result) {
 	// Create a new GET request
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Println("Error creating request:", err)
-		return
+		return result, fmt.Errorf("creating request: %w", err)
 	}
 
 	// Perform the request
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Println("Error performing request:", err)
-		return
+		return result, fmt.Errorf("performing request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Println("Error reading response body:", err)
-		return
+		return result, fmt.Errorf("reading response body: %w", err)
+	}
+
+	result.TotalAsset, err = parseHoldings(body)
+	if err != nil || result.TotalAsset == 0 {
+		return result, err
+	}
+
+	// NAV and shares outstanding are optional, the holdings stand without them
+	if result.NAV, result.SharesOutstanding, err = fundData(&client); err != nil {
+		log.Println("IBIT fund data unavailable:", err)
 	}
 
+	return result, nil
+}
+
+// parseHoldings returns the bitcoin held from the holdings rows
+func parseHoldings(body []byte) (float64, error) {
 	// Trim any leading characters that may cause the issue
 	bodyStr := string(body)
 	bodyStr = strings.TrimLeftFunc(bodyStr, func(r rune) bool {
@@ -55,21 +81,129 @@ func Collect() (result types.Result) {
 
 	var data FundData
 	if err := json.Unmarshal([]byte(bodyStr), &data); err != nil {
-		log.Printf("Error unmarshalling JSON: %v", err)
+		return 0, fmt.Errorf("unmarshalling JSON: %w", err)
 	}
 
 	// Iterate through the funds and find the one with ticker "BTC"
 	for _, fund := range data.AaData {
 		if len(fund) > 0 && fund[0] == "BTC" {
+			if len(fund) < 7 {
+				return 0, fmt.Errorf("BTC holding has %d columns, want at least 7", len(fund))
+			}
 			// Extract the "Shares" field
 			sharesMap, ok := fund[6].(map[string]interface{})
-			if ok {
-				sharesRaw, _ := sharesMap["raw"].(float64)
-				result.TotalAsset = sharesRaw
-				return
+			if !ok {
+				return 0, fmt.Errorf("BTC shares column is %T, want an object", fund[6])
+			}
+			sharesRaw, ok := sharesMap["raw"].(float64)
+			if !ok {
+				return 0, &parse.Error{Fund: "IBIT", Field: "shares", Raw: fmt.Sprint(sharesMap["raw"]), Err: parse.ErrSyntax}
+			}
+			return sharesRaw, nil
+		}
+	}
+
+	return 0, &parse.Error{Fund: "IBIT", Field: "BTC holding", Err: parse.ErrMissing}
+}
+
+// fundData fetches the NAV per share and shares outstanding from the product page
+func fundData(client *http.Client) (nav, shares float64, err error) {
+	resp, err := client.Get(productURL)
+	if err != nil {
+		return 0, 0, fmt.Errorf("performing request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("product page status %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading product page: %w", err)
+	}
+	return parseFundData(doc)
+}
+
+// parseFundData reads the NAV per share and shares outstanding of a product page
+func parseFundData(doc *goquery.Document) (nav, shares float64, err error) {
+	fields := []struct {
+		name, selector string
+		target         *float64
+	}{
+		{"NAV", navSelector, &nav},
+		{"Shares Outstanding", sharesSelector, &shares},
+	}
+	for _, field := range fields {
+		raw := doc.Find(field.selector).First().Text()
+		if *field.target, err = parse.Number("IBIT", field.name, raw); err != nil {
+			return 0, 0, err
+		}
+	}
+	return nav, shares, nil
+}
+
+// CollectCSV reads the holdings from the product page's CSV download,
+// independently of the ajax holdings used by Collect
+func CollectCSV() (result types.Result, err error) {
+	client := http.Client{}
+
+	resp, err := client.Get(holdingsCSVURL)
+	if err != nil {
+		return result, fmt.Errorf("performing request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("holdings CSV status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("reading response body: %w", err)
+	}
+
+	return parseHoldingsCSV(body)
+}
+
+// parseHoldingsCSV reads the as-of date above the holdings table and the
+// quantity of its BTC row
+func parseHoldingsCSV(body []byte) (result types.Result, err error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(body), "\ufeff")))
+	// The fund details above the table have fewer fields than its rows
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	quantity := -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("reading CSV: %w", err)
+		}
+		if len(record) == 0 {
+			continue
+		}
+
+		switch {
+		case record[0] == "Fund Holdings as of" && len(record) > 1:
+			if result.Date, err = parse.Date("IBIT", "holdings date", record[1], "Jan 02, 2006"); err != nil {
+				return result, err
+			}
+		case record[0] == "Ticker":
+			for i, column := range record {
+				if column == "Quantity" {
+					quantity = i
+				}
+			}
+		case record[0] == "BTC":
+			if quantity < 0 || quantity >= len(record) {
+				return result, fmt.Errorf("BTC holding without a Quantity column")
 			}
+			result.TotalAsset, err = parse.Number("IBIT", "BTC quantity", record[quantity])
+			return result, err
 		}
 	}
 
-	return result
+	return result, nil
 }
@@ -0,0 +1,49 @@
+package ibit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/jyap808/btcEtfScrape/parse"
+)
+
+func TestParseHoldings(t *testing.T) {
+	body := "\ufeff" + `{"aaData": [["USD", "USD CASH"], ["BTC", "BITCOIN", "-", "Cryptocurrency", {}, {}, {"display": "195,985.24", "raw": 195985.2436}]]}`
+	total, err := parseHoldings([]byte(body))
+	if err != nil || total != 195985.2436 {
+		t.Errorf("parseHoldings() = %v, %v, want 195985.2436", total, err)
+	}
+
+	if _, err := parseHoldings([]byte(`{"aaData": [["BTC", "BITCOIN"]]}`)); err == nil {
+		t.Error("parseHoldings() of a short row succeeded")
+	}
+
+	if _, err := parseHoldings([]byte(`{"aaData": [["USD", "USD CASH"]]}`)); !errors.Is(err, parse.ErrMissing) {
+		t.Errorf("parseHoldings() without a BTC row error = %v, want %v", err, parse.ErrMissing)
+	}
+}
+
+func TestParseFundData(t *testing.T) {
+	page := func(nav, shares string) *goquery.Document {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+			<ul><li class="navAmount"><span class="header-nav-label navAmount">NAV as of Mar 01, 2024</span>
+			<span class="header-nav-data">` + nav + `</span></li></ul>
+			<div class="float-left in-left col-sharesOutstanding"><span class="caption">Shares Outstanding</span>
+			<span class="data">` + shares + `</span></div></body></html>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+
+	nav, shares, err := parseFundData(page("\n $34.39 \n", "186,480,000"))
+	if err != nil || nav != 34.39 || shares != 186480000 {
+		t.Errorf("parseFundData() = %v, %v, %v", nav, shares, err)
+	}
+
+	if _, _, err := parseFundData(page("$34.39", "")); !errors.Is(err, parse.ErrEmpty) {
+		t.Errorf("parseFundData() without shares error = %v, want %v", err, parse.ErrEmpty)
+	}
+}
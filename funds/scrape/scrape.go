@@ -0,0 +1,125 @@
+/*
+Package scrape reports the page structure seen by the collectors that read
+issuer HTML.
+
+When an issuer redesigns a page the selectors a collector relies on stop
+matching and the collector returns nothing. Diagnostics records which
+selectors matched, how many candidate elements were examined and a
+fingerprint of the markup around the value, so a redesign can be told apart
+from a missing value and caught even while the selectors still match.
+*/
+package scrape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// Selector is a CSS selector, or a text or pattern match, and how often it matched
+type Selector struct {
+	Selector string `json:"selector"`
+	Matches  int    `json:"matches"`
+}
+
+// Diagnostics is the structure an HTML collector saw on one visit
+type Diagnostics struct {
+	URL        string     `json:"url"`
+	StatusCode int        `json:"statusCode"`
+	Selectors  []Selector `json:"selectors"`
+	// Candidates is how many elements were examined for the value
+	Candidates int `json:"candidates"`
+	// Fingerprint hashes the markup around the value, not its text
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Snapshot is the page as fetched
+	Snapshot []byte `json:"-"`
+}
+
+// New starts the diagnostics of a visit to url, expecting every selector to
+// match. The page is kept as the snapshot.
+func New(c *colly.Collector, url string, selectors ...string) *Diagnostics {
+	d := &Diagnostics{URL: url}
+	for _, selector := range selectors {
+		d.Selectors = append(d.Selectors, Selector{Selector: selector})
+	}
+
+	c.OnResponse(func(r *colly.Response) {
+		d.StatusCode = r.StatusCode
+		d.Snapshot = r.Body
+	})
+	c.OnError(func(r *colly.Response, _ error) {
+		d.StatusCode = r.StatusCode
+		d.Snapshot = r.Body
+	})
+
+	return d
+}
+
+// Match counts a match of an expected selector
+func (d *Diagnostics) Match(selector string) {
+	for i := range d.Selectors {
+		if d.Selectors[i].Selector == selector {
+			d.Selectors[i].Matches++
+			return
+		}
+	}
+	d.Selectors = append(d.Selectors, Selector{Selector: selector, Matches: 1})
+}
+
+// Candidate counts an element examined for the value
+func (d *Diagnostics) Candidate() {
+	d.Candidates++
+}
+
+// SetFingerprint hashes the parts describing the page's relevant structure
+func (d *Diagnostics) SetFingerprint(parts ...string) {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	d.Fingerprint = hex.EncodeToString(sum[:6])
+}
+
+// Missing returns the expected selectors that matched nothing
+func (d *Diagnostics) Missing() []string {
+	var missing []string
+	for _, selector := range d.Selectors {
+		if selector.Matches == 0 {
+			missing = append(missing, selector.Selector)
+		}
+	}
+	return missing
+}
+
+// Structure describes the elements of a selection by tag and class, ignoring
+// their text, so a changed value keeps the same structure
+func Structure(s *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(s *goquery.Selection, depth int)
+	walk = func(s *goquery.Selection, depth int) {
+		s.Each(func(_ int, el *goquery.Selection) {
+			b.WriteString(strings.Repeat(" ", depth))
+			b.WriteString(goquery.NodeName(el))
+			if class, ok := el.Attr("class"); ok {
+				classes := strings.Fields(class)
+				sort.Strings(classes)
+				b.WriteString("." + strings.Join(classes, "."))
+			}
+			b.WriteString("\n")
+			walk(el.Children(), depth+1)
+		})
+	}
+	walk(s, 0)
+	return b.String()
+}
+
+// Headers returns the trimmed text of each header cell of a table, the
+// columns a collector reads by position
+func Headers(table *goquery.Selection) string {
+	var headers []string
+	table.Find("th").Each(func(_ int, th *goquery.Selection) {
+		headers = append(headers, strings.Join(strings.Fields(th.Text()), " "))
+	})
+	return strings.Join(headers, "|")
+}
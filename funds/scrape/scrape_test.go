@@ -0,0 +1,78 @@
+package scrape
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+func document(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestStructure(t *testing.T) {
+	row := `<table><tr class="row holding"><td>XBTUSD</td><td>%s</td></tr></table>`
+	before := Structure(document(t, strings.Replace(row, "%s", "1,000.5", 1)).Find("tr"))
+	after := Structure(document(t, strings.Replace(row, "%s", "2,000.5", 1)).Find("tr"))
+	if before != after {
+		t.Errorf("Structure() changed with the value:\n%s\n%s", before, after)
+	}
+	if want := "tr.holding.row\n td\n td\n"; before != want {
+		t.Errorf("Structure() = %q, want %q", before, want)
+	}
+
+	redesigned := Structure(document(t, `<table><tr class="row"><td><span>XBTUSD</span></td><td>1</td></tr></table>`).Find("tr"))
+	if redesigned == before {
+		t.Error("Structure() did not change with the markup")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	doc := document(t, `<table><thead><tr><th> Ticker </th><th>Shares
+		Held</th></tr></thead></table>`)
+	if got := Headers(doc.Find("table")); got != "Ticker|Shares Held" {
+		t.Errorf("Headers() = %q", got)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	page := `<html><body><table class="holdings"><tr><td>BITCOIN</td><td class="qty">12.5</td></tr></table></body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	c := colly.NewCollector()
+	diag := New(c, server.URL, "table.holdings", "td.missing")
+	c.OnHTML("table.holdings", func(e *colly.HTMLElement) {
+		diag.Match("table.holdings")
+		e.ForEach("tr", func(_ int, row *colly.HTMLElement) {
+			diag.Candidate()
+			diag.SetFingerprint(Structure(row.DOM))
+		})
+	})
+	if err := c.Visit(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if diag.StatusCode != http.StatusOK || string(diag.Snapshot) != page {
+		t.Errorf("status %d, snapshot %q", diag.StatusCode, diag.Snapshot)
+	}
+	if diag.Candidates != 1 || diag.Fingerprint == "" {
+		t.Errorf("candidates %d, fingerprint %q", diag.Candidates, diag.Fingerprint)
+	}
+	if missing := diag.Missing(); !reflect.DeepEqual(missing, []string{"td.missing"}) {
+		t.Errorf("Missing() = %v", missing)
+	}
+}
@@ -1,41 +1,30 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/dustin/go-humanize"
-	"github.com/jyap808/btcEtfScrape/cmebrrny"
+	"github.com/jyap808/btcEtfScrape/config"
 	"github.com/jyap808/btcEtfScrape/funds"
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/outbox"
+	"github.com/jyap808/btcEtfScrape/pricing"
+	"github.com/jyap808/btcEtfScrape/revision"
+	"github.com/jyap808/btcEtfScrape/rules"
 	"github.com/jyap808/btcEtfScrape/types"
-	"github.com/michimani/gotwi"
-	"github.com/michimani/gotwi/tweet/managetweet"
-	gotwiTypes "github.com/michimani/gotwi/tweet/managetweet/types"
 )
 
-type payload struct {
-	Username  string  `json:"username"`
-	AvatarURL string  `json:"avatar_url"`
-	Embeds    []embed `json:"embeds"`
-}
-
-type embed struct {
-	Title       string `json:"title"`
-	URL         string `json:"url"`
-	Description string `json:"description"`
-}
-
 type manualData struct {
 	Ticker string
 	Result types.Result
@@ -46,229 +35,503 @@ type tickerDetail struct {
 	Description string
 	Note        string
 	Delayed     bool
+	// Issuer holdings page or document
+	URL string
 }
 
 var (
-	webhookURL string
-
+	// Discord settings given on the command line replace the config file
+	webhookURL     string
 	avatarUsername string
 	avatarURL      string
 
+	// Directory of user edited message templates
+	templateDir string
+	renderer    *message.Renderer
+
+	// Optional social channels, enabled when set
+	blueskyHost   string
+	blueskyHandle string
+	mastodonURL   string
+
+	// Render notifications to dryRunWriter instead of sending them
+	dryRun       bool
+	dryRunOutput string
+	dryRunWriter io.Writer = os.Stdout
+
+	// Price providers in fallback order
+	priceProviders string
+	priceCSV       string
+	priceCache     *pricing.Cache
+	cmeProvider    pricing.CME
+
+	// Every price seen by New York fixing day, for holdings dated before the
+	// provider window, with an optional CSV backfill of BRRNY fixings
+	priceHistoryPath string
+	priceHistory     *pricing.History
+	brrnyImport      string
+
+	// Alert rules file, reloaded when it changes
+	rulesPath   string
+	rulesEngine *rules.Engine
+
+	// Admin endpoint credentials and audit trail
+	adminCredentials adminAuth
+	auditPath        string
+
+	// Pending notifications
+	outboxPath    string
+	notifications *outbox.Outbox
+
+	// Every change to the accepted results
+	revisionsPath string
+	revisions     *revision.Store
+
+	// Pages saved when an HTML source's structure drifts, and the structure
+	// each source was last seen with
+	snapshotDir    string
+	driftStatePath string
+
 	// track
 	tickerResults         = map[string]types.Result{}
 	tickerResultsOverride = map[string]types.Result{}
-	asset_rr              [5]cmebrrny.ReferenceRate
-
-	// polling intervals
-	pollMinutes  int = 5
-	backoffHours int = 12
-
-	// Skip X post when the difference is under this threshold
-	minBitcoinDiff float64 = 1.0
-
-	tickerDetails = map[string]tickerDetail{
-		"ARKB": {Description: "Ark 21Shares", Note: "ARKB holdings are usually updated 10+ hours after the close of trading"}, // ARK 21Shares Bitcoin ETF
-		"BITB": {Description: "Bitwise", Note: "BITB holdings are usually updated 4.5+ hours after the close of trading"},     // Bitwise Bitcoin ETF
-		"BRRR": {Description: "Valkyrie", Note: "BRRR holdings are usually updated 10+ hours after the close of trading"},     // Valkyrie Bitcoin Fund
-		"BTCW": {Description: "WisdomTree", Note: ""},                                                                         // WisdomTree Bitcoin Fund
-		"DEFI": {Description: "Hashdex", Note: ""},                                                                            // Hashdex Bitcoin ETF
-		"EZBC": {Description: "Franklin", Note: "EZBC holdings are usually updated 5.5+ hours after the close of trading"},    // Franklin Bitcoin ETF
-		"FBTC": {Description: "Fidelity", Note: "FBTC holdings are usually updated 16+ hours after the close of trading"},     // Fidelity Wise Origin Bitcoin Fund
-		"GBTC": {Description: "Grayscale", Note: "GBTC holdings are usually updated 1 day late", Delayed: true},               // Grayscale Bitcoin Trust
-		"HODL": {Description: "VanEck", Note: "HODL holdings are usually updated 1 day late", Delayed: true},                  // VanEck Bitcoin Trust
-		"IBIT": {Description: "BlackRock", Note: "IBIT holdings are usually updated 13+ hours after the close of trading"},    // iShares Bitcoin Trust
-	}
-	// BTCO - Invesco Galaxy Bitcoin ETF
+	// Guards tickerResults and tickerResultsOverride
+	resultsMu sync.Mutex
+
+	// Optional image attached to the daily summary
+	summaryImage string
+
+	// Settings file, defaults when empty
+	configPath string
+	cfg        = config.Default()
+	// Enabled funds from cfg
+	tickerDetails = fundDetails(cfg)
+	// Guards cfg, tickerDetails and renderer, which are replaced on reload
+	configMu sync.RWMutex
 )
 
 const (
 	OAuthTokenEnvKeyName       = "GOTWI_ACCESS_TOKEN"
 	OAuthTokenSecretEnvKeyName = "GOTWI_ACCESS_TOKEN_SECRET"
+
+	BlueskyAppPasswordEnvKeyName  = "BLUESKY_APP_PASSWORD"
+	MastodonAccessTokenEnvKeyName = "MASTODON_ACCESS_TOKEN"
+
+	ConfigPathEnvKeyName = "BTCETF_CONFIG"
 )
 
-func init() {
-	flag.StringVar(&webhookURL, "webhookURL", "https://discord.com/api/webhooks/", "Webhook URL")
-	flag.StringVar(&avatarUsername, "avatarUsername", "Annalee Call", "Avatar username")
-	flag.StringVar(&avatarURL, "avatarURL", "https://static1.personality-database.com/profile_images/6604632de9954b4d99575e56404bd8b7.png", "Avatar image URL")
-	flag.Parse()
+// registerFlags defines the command line flags
+func registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&configPath, "config", os.Getenv(ConfigPathEnvKeyName), "YAML settings file")
+	fs.StringVar(&webhookURL, "webhookURL", "", "Webhook URL, replaces discord.webhookURL")
+	fs.StringVar(&avatarUsername, "avatarUsername", "", "Avatar username, replaces discord.avatarUsername")
+	fs.StringVar(&avatarURL, "avatarURL", "", "Avatar image URL, replaces discord.avatarURL")
+	fs.StringVar(&templateDir, "templateDir", "", "Directory of message templates overriding the defaults")
+	fs.StringVar(&blueskyHost, "blueskyHost", "https://bsky.social", "Bluesky PDS host")
+	fs.StringVar(&blueskyHandle, "blueskyHandle", "", "Bluesky handle, enables Bluesky posts")
+	fs.StringVar(&mastodonURL, "mastodonURL", "", "Mastodon instance URL, enables Mastodon posts")
+	fs.StringVar(&outboxPath, "outboxPath", "outbox.json", "File storing pending and sent notifications")
+	fs.StringVar(&summaryImage, "summaryImage", "", "Image file attached to the Discord daily summary")
+	fs.BoolVar(&dryRun, "dry-run", false, "Render notifications without sending them or saving any state")
+	fs.StringVar(&dryRunOutput, "dry-run-output", "", "File for dry run output, defaults to stdout")
+	fs.StringVar(&rulesPath, "rulesPath", "", "JSON file of alert rules")
+	fs.StringVar(&priceProviders, "priceProviders", "cmebrrny,coinbase", "Price providers in fallback order: cmebrrny, coinbase, csv")
+	fs.StringVar(&priceCSV, "priceCSV", "", "CSV file of date,price rows for the csv price provider")
+	fs.StringVar(&priceHistoryPath, "priceHistoryPath", "price_history.json", "File storing every price seen")
+	fs.StringVar(&brrnyImport, "brrnyImport", "", "CSV file of date,value BRRNY fixings to import into the price history at startup")
+	fs.StringVar(&auditPath, "auditPath", "audit.log", "File recording every admin change")
+	fs.StringVar(&revisionsPath, "revisionsPath", "revisions.json", "File storing every change to accepted holdings")
+	fs.StringVar(&snapshotDir, "snapshotDir", "snapshots", "Directory saving issuer pages whose structure drifted, empty disables")
+	fs.StringVar(&driftStatePath, "driftStatePath", "drift_state.json", "File storing the page structure each HTML source was last seen with")
 }
 
 func main() {
+	collectCommand()
+	registerFlags(flag.CommandLine)
+	flag.Parse()
+
+	c, err := loadConfig(configPath)
+	if err != nil {
+		log.Fatalln("Error: config error:", err)
+	}
+	r, err := message.NewRenderer(templateDir)
+	if err != nil {
+		log.Fatalln("Error: message template error:", err)
+	}
+	applySettings(c, r)
+
 	// Initialize empty tickerResult
-	wgCount := 0
-	for ticker := range tickerDetails {
+	for ticker := range details() {
 		tickerResults[ticker] = types.Result{}
 		tickerResultsOverride[ticker] = types.Result{}
-		wgCount++
 	}
 
-	// Initialize cmebrrnyRR
-	asset_rr = getCMEBRRNYRR()
-	if asset_rr[0].Value == 0 {
-		log.Fatalln("Error: CME BRR NY initialization error")
+	adminCredentials = loadAdminAuth()
+	if !adminCredentials.configured() {
+		log.Printf("Warning: admin endpoints disabled, set %s or %s", AdminTokensEnvKeyName, AdminHMACSecretEnvKeyName)
 	}
 
-	var wg sync.WaitGroup
+	if dryRun {
+		// Nothing a dry run does is saved, so it can run against production paths
+		revisionsPath, priceHistoryPath, driftStatePath, snapshotDir, auditPath = "", "", "", "", ""
+		if dryRunOutput != "" {
+			f, err := os.OpenFile(dryRunOutput, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				log.Fatalln("Error: dry run output error:", err)
+			}
+			defer f.Close()
+			dryRunWriter = f
+		}
+		log.Println("Dry run: notifications are rendered but not sent, and no state is saved")
+	} else {
+		notifications, err = newOutbox(outboxPath)
+		if err != nil {
+			log.Fatalln("Error: outbox error:", err)
+		}
+		go notifications.Run(context.Background())
+	}
+
+	revisions, err = revision.Open(revisionsPath)
+	if err != nil {
+		log.Fatalln("Error: revisions error:", err)
+	}
+
+	if err := loadDriftStates(driftStatePath); err != nil {
+		log.Fatalln("Error: drift state error:", err)
+	}
+
+	rulesEngine, err = newRulesEngine(rulesPath)
+	if err != nil {
+		log.Fatalln("Error: rules error:", err)
+	}
+	go runRules()
 
-	// Increment the WaitGroup counter for each scraping function
-	wg.Add(wgCount)
+	priceProvider, err := newPriceProvider(priceProviders)
+	if err != nil {
+		log.Fatalln("Error: price provider error:", err)
+	}
+
+	priceHistory, err = pricing.OpenHistory(priceHistoryPath)
+	if err != nil {
+		log.Fatalln("Error: price history error:", err)
+	}
+	if brrnyImport != "" {
+		if err := importBRRNY(priceHistory, brrnyImport); err != nil {
+			log.Fatalln("Error: BRRNY import error:", err)
+		}
+	}
+	priceCache = pricing.NewCache(priceProvider, priceHistory)
+
+	// Initialize prices. Flows are reported as errors until a provider succeeds.
+	if prices := getPrices(); len(prices) == 0 {
+		log.Println("Warning: no price provider available")
+	}
+	go priceCache.Run(context.Background())
 
 	// Launch goroutines for scraping functions
-	go handleFund(&wg, funds.ArkbCollect, "ARKB")
-	go handleFund(&wg, funds.BitbCollect, "BITB")
-	go handleFund(&wg, funds.BrrrCollect, "BRRR")
-	go handleFund(&wg, funds.BtcwCollect, "BTCW")
-	go handleFund(&wg, funds.DefiCollect, "DEFI")
-	go handleFund(&wg, funds.EzbcCollect, "EZBC")
-	go handleFund(&wg, funds.FbtcCollect, "FBTC")
-	go handleFund(&wg, funds.GbtcCollect, "GBTC")
-	go handleFund(&wg, funds.HodlCollect, "HODL")
-	go handleFund(&wg, funds.IbitCollect, "IBIT")
+	syncFunds()
+
+	go runDailySummary()
 
 	// Manual endpoints
-	http.HandleFunc("/override", handleOverride)
-	http.HandleFunc("/update", handleUpdate)
+	http.HandleFunc("/override", requireAdmin(http.MethodPost, handleOverride))
+	http.HandleFunc("/update", requireAdmin(http.MethodPost, handleUpdate))
+	http.HandleFunc("/prices", handlePrices)
+	http.HandleFunc("/outbox/failed", requireAdmin(http.MethodGet, handleOutboxFailed))
+	http.HandleFunc("/outbox/requeue", requireAdmin(http.MethodPost, handleOutboxRequeue))
+	http.HandleFunc("/dashboard", handleDashboard)
+	http.HandleFunc("/state", requireAdmin(http.MethodGet, handleState))
+	http.HandleFunc("/preview", requireAdmin(http.MethodPost, handlePreview))
+	http.HandleFunc("/batch", requireAdmin(http.MethodPost, handleBatch))
+	http.HandleFunc("/revisions", requireAdmin(http.MethodGet, handleRevisions))
+	http.HandleFunc("/rollback", requireAdmin(http.MethodPost, handleRollback))
+	http.HandleFunc("/reload", requireAdmin(http.MethodPost, handleReload))
+	http.HandleFunc("/quarantine", requireAdmin(http.MethodGet, handleQuarantine))
+	http.HandleFunc("/quarantine/confirm", requireAdmin(http.MethodPost, handleQuarantineConfirm))
+	http.HandleFunc("/quarantine/reject", requireAdmin(http.MethodPost, handleQuarantineReject))
+	http.HandleFunc("/diagnostics", requireAdmin(http.MethodGet, handleDiagnostics))
+	http.HandleFunc("/staleness", requireAdmin(http.MethodGet, handleStaleness))
 
 	// Start HTTP server in a separate goroutine
 	go func() {
-		if err := http.ListenAndServe(":8080", nil); err != nil {
+		if err := http.ListenAndServe(conf().Listen, nil); err != nil {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 	}()
 
-	// Wait for all goroutines to finish
-	wg.Wait()
-
-	log.Println("All scraping functions have finished.")
+	// Reload the config file on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if _, _, err := reloadConfig(); err != nil {
+			log.Println("Reload error, keeping the current config:", err)
+		}
+	}
 }
 
-// Generic handler
-func handleFund(wg *sync.WaitGroup, collector func() types.Result, ticker string) {
-	defer wg.Done() // Decrement the WaitGroup counter when the goroutine finishes
-
-	for {
+// Generic handler, runs until ctx is cancelled when the fund is disabled
+func handleFund(ctx context.Context, ticker string) {
+	for ctx.Err() == nil {
 		var newResult types.Result
 		override := false
+		confirmed := false
+		var collected funds.Outcome
 
 		// Check if there is a manual override set
-		if tickerResultsOverride[ticker].TotalAsset != 0 {
-			newResult = tickerResultsOverride[ticker]
-
-			// Clear override
-			tickerResultsOverride[ticker] = types.Result{}
+		if result, ok := takeOverride(ticker); ok {
+			newResult = result
 			override = true
+		} else if result, ok := takeConfirmed(ticker); ok {
+			// An operator confirmed a quarantined result
+			newResult = result
+			confirmed = true
+		} else if conf().Funds[ticker].PublicationWindow.Wait(time.Now()) > 0 {
+			// Outside the publication window only overrides are applied
+			sleep(ctx, conf().FundPollInterval(ticker))
+			continue
 		} else {
-			newResult = collector()
+			collected = collectFund(ticker)
+			newResult = reconcile(ticker, collected.Result)
+		}
+		current := currentResult(ticker)
+		recordScrape(ticker, time.Now(), newResult, override, collected.Err())
+		if collected.Disagreement != "" {
+			setStatus(ticker, statusDisagree)
 		}
 
 		// Check date is valid. Date is optional so we check it is not none
-		if !newResult.Date.IsZero() && newResult.Date.Before(tickerResults[ticker].Date) {
+		if !newResult.Date.IsZero() && newResult.Date.Before(current.Date) {
 			log.Printf("%s new result before current: %+v", ticker, newResult)
+			setStatus(ticker, statusStaleDate)
 
 			// Backoff for 1 hr or this just will loop
-			time.Sleep(time.Hour * time.Duration(1))
+			sleep(ctx, time.Hour)
+
+			continue
+		}
 
+		if newResult.TotalAsset < 0 {
+			log.Printf("%s negative holdings rejected: %+v", ticker, newResult)
+			setStatus(ticker, statusNegative)
+			sleep(ctx, conf().FundPollInterval(ticker))
 			continue
 		}
 
-		if newResult.TotalAsset != tickerResults[ticker].TotalAsset && newResult.TotalAsset != 0 {
-			if tickerResults[ticker].TotalAsset == 0 {
+		// Hold implausible scrapes until a second scrape or an operator confirms them
+		if !override && !confirmed && newResult.TotalAsset != 0 && !guardScrape(ticker, current, newResult) {
+			sleep(ctx, conf().FundPollInterval(ticker))
+			continue
+		}
+
+		if newResult.TotalAsset != current.TotalAsset && newResult.TotalAsset != 0 {
+			// A rollback or manual update while this poll ran wins, the
+			// next poll compares with it
+			if !replaceResult(ticker, current, newResult) {
+				log.Printf("%s result changed while polling, %+v not applied", ticker, newResult)
+				if override {
+					restoreOverride(ticker, newResult)
+				}
+				sleep(ctx, conf().FundPollInterval(ticker))
+				continue
+			}
+
+			if current.TotalAsset == 0 {
 				// initialize
-				tickerResults[ticker] = newResult
-				log.Printf("Initialize %s: %+v", ticker, tickerResults[ticker])
+				log.Printf("Initialize %s: %+v", ticker, newResult)
+
+				// Restarts only post the holdings they start from when asked to
+				var post *revision.Post
+				if conf().PostInitialization {
+					event := message.Event{Ticker: ticker, Description: details()[ticker].Description,
+						Date: newResult.Date, TotalAsset: newResult.TotalAsset}
+					notify(message.Initialization, event)
+					post = postFor(message.Initialization, event)
+				}
+				recordRevision(ticker, scrapeCause(override), "", current, newResult, post)
 			} else {
 				// compare
-				assetDiff := newResult.TotalAsset - tickerResults[ticker].TotalAsset
-				rr := getCMEBRRNYRR()
-				assetPrice := rr[0].Value
-				if tickerDetails[ticker].Delayed {
-					assetPrice = rr[1].Value
+				kind, event := buildFlowEvent(ticker, current, newResult, override)
+
+				if event.Price == 0 {
+					notify(message.Error, message.Event{Ticker: ticker, Description: details()[ticker].Description,
+						Date: newResult.Date, Error: "reference price unavailable"})
 				}
-				flowDiff := assetDiff * assetPrice
 
-				header := ticker
-				if newResult.Date != (time.Time{}) {
-					layout := "01/02/2006"
-					formattedTime := newResult.Date.Format(layout)
+				notify(kind, event)
+				post := postFor(kind, event)
+				recordDailyFlow(event, post)
+				evaluateRules(event)
 
-					header = fmt.Sprintf("%s %s", ticker, formattedTime)
-				}
+				setLastFlow(ticker, event)
+				recordRevision(ticker, scrapeCause(override), "", current, newResult, post)
 
-				msg := fmt.Sprintf("%s\nCHANGE Bitcoin: %.1f\nTOTAL Bitcoin: %.1f\nDETAILS Flow: $%.1f, CMEBRRNY: $%.1f",
-					header, assetDiff, newResult.TotalAsset,
-					flowDiff, rr[0].Value)
+				log.Printf("Update %s: %+v", ticker, newResult)
 
-				postDiscord(msg)
+				sleep(ctx, time.Duration(conf().Backoff))
+			}
+		}
 
-				flowEmoji := "🚀"
-				if assetDiff < 0 {
-					flowEmoji = "👎"
-				}
+		sleep(ctx, conf().FundPollInterval(ticker))
+	}
+	log.Printf("Stopped %s", ticker)
+}
 
-				note := ""
-				if !override {
-					note = tickerDetails[ticker].Note
-				}
+// buildFlowEvent compares a new result with the current one and prices the change
+func buildFlowEvent(ticker string, current, newResult types.Result, override bool) (message.Kind, message.Event) {
+	assetDiff := newResult.TotalAsset - current.TotalAsset
+	price, priceNote := priceFor(ticker, newResult.Date)
+	assetPrice := price.Value
+	flowDiff := assetDiff * assetPrice
+	navFlow, hasNavFlow := newResult.NavFlow(current)
+
+	kind := message.Flow
+	note := details()[ticker].Note
+	if override {
+		kind = message.Override
+		note = ""
+	}
 
-				xMsg := fmt.Sprintf("%s $%s\n\n%s FLOW: %s BTC, $%s\n🏦 TOTAL Bitcoin in Trust: %s $BTC\n\n%s",
-					tickerDetails[ticker].Description, ticker,
-					flowEmoji, humanize.CommafWithDigits(assetDiff, 2), humanize.CommafWithDigits(flowDiff, 0),
-					humanize.CommafWithDigits(newResult.TotalAsset, 1), note)
+	event := message.Event{
+		Ticker:      ticker,
+		Description: details()[ticker].Description,
+		Date:        newResult.Date,
+		AssetDiff:   assetDiff,
+		TotalAsset:  newResult.TotalAsset,
+		FlowDiff:    flowDiff,
+		NavFlow:     navFlow,
+		HasNavFlow:  hasNavFlow,
+		Price:       assetPrice,
+		PriceSource: price.Provider,
+		PriceDate:   price.Date,
+		PriceNote:   priceNote,
+		Note:        note,
+	}
 
-				// Reporting threshold check. Get the absolute difference
-				absAssetDiff := math.Abs(assetDiff)
-				if absAssetDiff > minBitcoinDiff {
-					postTweet(xMsg)
-				}
+	return kind, event
+}
 
-				tickerResults[ticker] = newResult
+// takeOverride returns and clears a pending manual override
+func takeOverride(ticker string) (types.Result, bool) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
 
-				log.Printf("Update %s: %+v", ticker, tickerResults[ticker])
+	result := tickerResultsOverride[ticker]
+	if result.TotalAsset == 0 {
+		return types.Result{}, false
+	}
+	tickerResultsOverride[ticker] = types.Result{}
 
-				time.Sleep(time.Hour * time.Duration(backoffHours))
-			}
-		}
+	return result, true
+}
 
-		time.Sleep(time.Minute * time.Duration(pollMinutes))
+// restoreOverride queues an override taken but not applied again, unless a
+// newer one was set meanwhile
+func restoreOverride(ticker string, result types.Result) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	if tickerResultsOverride[ticker].TotalAsset == 0 {
+		tickerResultsOverride[ticker] = result
 	}
 }
 
+// currentResult returns the accepted result for a ticker
+func currentResult(ticker string) types.Result {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	return tickerResults[ticker]
+}
+
+// setResult accepts a new result for a ticker
+func setResult(ticker string, result types.Result) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	tickerResults[ticker] = result
+}
+
+// replaceResult accepts a new result for a ticker unless its result changed
+// from previous since it was read, such as by a rollback or a manual update
+func replaceResult(ticker string, previous, result types.Result) bool {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	if tickerResults[ticker] != previous {
+		return false
+	}
+	tickerResults[ticker] = result
+	return true
+}
+
+// Manual dates older than this are rejected
+const maxManualAge = 31 * 24 * time.Hour
+
+// validateManualData checks a manual result before it is applied
+func validateManualData(data manualData, updateType string) error {
+	if _, ok := details()[data.Ticker]; !ok {
+		return fmt.Errorf("unknown ticker %q", data.Ticker)
+	}
+	if data.Result.TotalAsset < 0 {
+		return fmt.Errorf("total must not be negative")
+	}
+	if updateType == "override" && data.Result.TotalAsset == 0 {
+		return fmt.Errorf("override total must be greater than zero")
+	}
+	if date := data.Result.Date; !date.IsZero() {
+		if date.After(time.Now().Add(24 * time.Hour)) {
+			return fmt.Errorf("date %s is in the future", date.Format("2006-01-02"))
+		}
+		if date.Before(time.Now().Add(-maxManualAge)) {
+			return fmt.Errorf("date %s is more than 31 days old", date.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
 func handleData(w http.ResponseWriter, r *http.Request, updateType string) {
 	var data manualData
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Error reading request body: %v", err)
-		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		log.Printf("Error unmarshalling JSON: %v", err)
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
 		return
 	}
-	defer r.Body.Close()
 
-	if err := json.Unmarshal(body, &data); err != nil {
-		log.Printf("Error unmarshalling JSON: %v", err)
+	if err := validateManualData(data, updateType); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 
-	// Set based on updateType
-	var results map[string]types.Result
+	// Update the corresponding map
+	newResult := types.Result{TotalAsset: data.Result.TotalAsset, Date: data.Result.Date}
+
+	resultsMu.Lock()
+	var before types.Result
 	switch updateType {
 	case "override":
-		results = tickerResultsOverride
+		before = tickerResultsOverride[data.Ticker]
+		tickerResultsOverride[data.Ticker] = newResult
 	case "update":
-		results = tickerResults
-	default:
-		log.Println("Invalid update type")
-		return
+		before = tickerResults[data.Ticker]
+		tickerResults[data.Ticker] = newResult
 	}
+	resultsMu.Unlock()
 
-	// Update the corresponding map
-	newResult := types.Result{TotalAsset: data.Result.TotalAsset, Date: data.Result.Date}
-	results[data.Ticker] = newResult
+	audit(r, updateType, data.Ticker, before, newResult)
+	if updateType == "update" {
+		recordRevision(data.Ticker, revision.Update, actorFrom(r), before, newResult, nil)
+	}
 
 	// Log and respond with success message
-	log.Printf("Data %s %s: %+v", updateType, data.Ticker, results[data.Ticker])
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Data %s successful\n", updateType)
+	log.Printf("Data %s %s by %s: %+v", updateType, data.Ticker, actorFrom(r), newResult)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": updateType + " successful",
+		"ticker": data.Ticker,
+		"result": newResult,
+	})
 }
 
 func handleOverride(w http.ResponseWriter, r *http.Request) {
@@ -279,78 +542,81 @@ func handleUpdate(w http.ResponseWriter, r *http.Request) {
 	handleData(w, r, "update")
 }
 
-func getCMEBRRNYRR() [5]cmebrrny.ReferenceRate {
-	if len(asset_rr) > 0 {
-		// Cache the value once every 24 hours
-		firstDate := time.Now()
-		secondDate := asset_rr[0].Date
-		difference := firstDate.Sub(secondDate)
-		if difference.Hours() < 24 {
-			return asset_rr
-		}
+func getPrices() []pricing.Price {
+	return priceCache.Prices(context.Background())
+}
+
+// handlePrices shows the price cache state
+func handlePrices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(priceCache.State()); err != nil {
+		log.Println("Error encoding price cache:", err)
 	}
+}
 
-	rr, err := cmebrrny.GetBRRYNY()
-	if err != nil {
-		log.Println("Get Reference Rate error:", err)
-		if len(asset_rr) > 0 {
-			return asset_rr
-		} else {
-			return [5]cmebrrny.ReferenceRate{}
+// priceFor returns the price fixed on the holdings trade date, with a note
+// when that exact fixing is not available
+func priceFor(ticker string, date time.Time) (pricing.Price, string) {
+	prices := getPrices()
+
+	// Undated results use the latest fixing, or the one before for funds that publish a day late
+	if date.IsZero() {
+		if details()[ticker].Delayed && len(prices) > 1 {
+			return prices[1], ""
+		} else if !details()[ticker].Delayed && len(prices) > 0 {
+			return prices[0], ""
 		}
+		return pricing.Price{}, ""
 	}
 
-	asset_rr = rr
-
-	log.Println("Set Reference Rate:", asset_rr)
+	price, exact, found := pricing.Lookup(prices, date, priceHistory)
+	if !found {
+		return pricing.Price{}, fmt.Sprintf("No price available for %s", date.Format("01/02/2006"))
+	}
+	if !exact {
+		return price, fmt.Sprintf("No %s fixing for %s, priced at %s", price.Provider,
+			date.Format("01/02/2006"), price.Date.Format("01/02/2006"))
+	}
 
-	return asset_rr
+	return price, ""
 }
 
-func postDiscord(msg string) {
-	blockEmbed := embed{Description: msg}
-	embeds := []embed{blockEmbed}
-	jsonReq := payload{Username: avatarUsername, AvatarURL: avatarURL, Embeds: embeds}
-
-	jsonStr, _ := json.Marshal(jsonReq)
-	log.Println("Discord POST:", string(jsonStr))
-
-	req, _ := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonStr))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// importBRRNY backfills the price history with BRRNY fixings from a CSV file
+func importBRRNY(history *pricing.History, path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		log.Println(err)
-		return
-	}
-	defer resp.Body.Close()
-}
-
-func postTweet(msg string) {
-	in := &gotwi.NewClientInput{
-		AuthenticationMethod: gotwi.AuthenMethodOAuth1UserContext,
-		OAuthToken:           os.Getenv(OAuthTokenEnvKeyName),
-		OAuthTokenSecret:     os.Getenv(OAuthTokenSecretEnvKeyName),
+		return err
 	}
+	defer f.Close()
 
-	c, err := gotwi.NewClient(in)
+	n, err := history.ImportCSV(f, cmeProvider.Name())
 	if err != nil {
-		log.Println(err)
-		return
+		return fmt.Errorf("%s: %w", path, err)
 	}
 
-	p := &gotwiTypes.CreateInput{
-		Text: gotwi.String(msg),
-	}
+	log.Printf("Imported %d BRRNY fixings from %s, %d prices stored", n, path, history.Len())
 
-	// Replace newline characters with spaces
-	logStr := strings.ReplaceAll(msg, "\n", " ")
-	log.Println("X Tweet:", logStr)
+	return nil
+}
 
-	_, err = managetweet.Create(context.Background(), c, p)
-	if err != nil {
-		log.Println(err.Error())
-		return
+// newPriceProvider chains the named providers in order
+func newPriceProvider(names string) (pricing.Provider, error) {
+	fallback := &pricing.Fallback{}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "cmebrrny":
+			fallback.Providers = append(fallback.Providers, cmeProvider)
+		case "coinbase":
+			fallback.Providers = append(fallback.Providers, pricing.Coinbase{Client: &http.Client{Timeout: 30 * time.Second}})
+		case "csv":
+			if priceCSV == "" {
+				return nil, fmt.Errorf("csv price provider needs -priceCSV")
+			}
+			fallback.Providers = append(fallback.Providers, pricing.CSV{Path: priceCSV})
+		default:
+			return nil, fmt.Errorf("unknown price provider %q", name)
+		}
 	}
+
+	return fallback, nil
 }
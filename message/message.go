@@ -0,0 +1,170 @@
+/*
+Package message renders notification bodies from Go text/template files.
+
+Each output channel has one template per event kind, named
+<channel>_<kind>.tmpl. Defaults are embedded in the binary and any file with
+the same name in a user supplied directory replaces the default.
+*/
+package message
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+type Channel string
+
+const (
+	Discord Channel = "discord"
+	X       Channel = "x"
+)
+
+type Kind string
+
+const (
+	Flow           Kind = "flow"
+	Initialization Kind = "initialization"
+	Override       Kind = "override"
+	Summary        Kind = "summary"
+	Error          Kind = "error"
+	Alert          Kind = "alert"
+	Correction     Kind = "correction"
+)
+
+// Channels and Kinds list every known template combination
+var (
+	Channels = []Channel{Discord, X}
+	Kinds    = []Kind{Flow, Initialization, Override, Summary, Error, Alert, Correction}
+)
+
+// Event holds the data available to every template
+type Event struct {
+	Ticker      string
+	Description string
+	Date        time.Time
+	AssetDiff   float64
+	TotalAsset  float64
+	FlowDiff    float64
+	// NavFlow is the shares outstanding based flow, set when HasNavFlow
+	NavFlow     float64
+	HasNavFlow  bool
+	Price       float64
+	PriceSource string
+	PriceDate   time.Time
+	// PriceNote explains a price that was not fixed on the trade date
+	PriceNote string
+	Note      string
+	Error     string
+
+	// Rule and Alert are only set for alerts
+	Rule  string
+	Alert string
+
+	// RetractedTotal is the total posted in error, only set for corrections
+	RetractedTotal float64
+
+	// Flows is only set for the daily summary
+	Flows []Event
+}
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+type Renderer struct {
+	templates map[string]*template.Template
+}
+
+// Funcs are the helper functions available to every template
+var Funcs = template.FuncMap{
+	"comma": humanize.CommafWithDigits,
+	"fixed": func(v float64, decimals int) string {
+		return fmt.Sprintf("%.*f", decimals, v)
+	},
+	"abs": math.Abs,
+	"flowEmoji": func(v float64) string {
+		if v < 0 {
+			return "👎"
+		}
+		return "🚀"
+	},
+	"date": func(layout string, t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(layout)
+	},
+}
+
+func templateName(channel Channel, kind Kind) string {
+	return fmt.Sprintf("%s_%s.tmpl", channel, kind)
+}
+
+// NewRenderer loads the default templates, replacing any that also exist in dir.
+// An empty dir uses only the defaults.
+func NewRenderer(dir string) (*Renderer, error) {
+	r := &Renderer{templates: map[string]*template.Template{}}
+
+	for _, channel := range Channels {
+		for _, kind := range Kinds {
+			name := templateName(channel, kind)
+
+			text, err := defaultTemplates.ReadFile("templates/" + name)
+			if err != nil {
+				// No default for this combination
+				text = nil
+			}
+
+			if dir != "" {
+				custom, err := os.ReadFile(filepath.Join(dir, name))
+				if err == nil {
+					text = custom
+				} else if !os.IsNotExist(err) {
+					return nil, err
+				}
+			}
+
+			if text == nil {
+				continue
+			}
+
+			tmpl, err := template.New(name).Funcs(Funcs).Parse(string(text))
+			if err != nil {
+				return nil, err
+			}
+			r.templates[name] = tmpl
+		}
+	}
+
+	return r, nil
+}
+
+// Has reports whether a template exists for the channel and kind
+func (r *Renderer) Has(channel Channel, kind Kind) bool {
+	_, ok := r.templates[templateName(channel, kind)]
+	return ok
+}
+
+// Render executes the template for the channel and kind. An empty string
+// with no error means there is nothing to send on that channel.
+func (r *Renderer) Render(channel Channel, kind Kind, event Event) (string, error) {
+	tmpl, ok := r.templates[templateName(channel, kind)]
+	if !ok {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
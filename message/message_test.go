@@ -0,0 +1,107 @@
+package message
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var testEvents = map[Kind]Event{
+	Flow: {
+		Ticker: "IBIT", Description: "BlackRock", Date: time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC),
+		AssetDiff: 2345.67, TotalAsset: 128765.4, FlowDiff: 121600123.45, Price: 51840.37, PriceSource: "CMEBRRNY",
+		NavFlow: 121010500, HasNavFlow: true,
+		Note: "IBIT holdings are usually updated 13+ hours after the close of trading",
+	},
+	Initialization: {
+		Ticker: "ARKB", Description: "Ark 21Shares", Date: time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC),
+		TotalAsset: 30123.5,
+	},
+	Override: {
+		Ticker: "GBTC", Description: "Grayscale", Date: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC),
+		AssetDiff: -1544.12, TotalAsset: 436008.9, FlowDiff: -80048162.5, Price: 51840.37, PriceSource: "CMEBRRNY",
+		PriceNote: "No CMEBRRNY fixing for 02/15/2024, priced at 02/14/2024",
+	},
+	Summary: {
+		Date: time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC), AssetDiff: 801.55, FlowDiff: 41551960.95,
+		Flows: []Event{
+			{Ticker: "GBTC", AssetDiff: -1544.12, FlowDiff: -80048162.5},
+			{Ticker: "IBIT", AssetDiff: 2345.67, FlowDiff: 121600123.45},
+		},
+	},
+	Error: {
+		Ticker: "FBTC", Error: "no reference rate available",
+	},
+	Alert: {
+		Ticker: "IBIT", Description: "BlackRock", Date: time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC),
+		Rule: "big-inflow", Alert: "USD flow $250000000 above $200000000",
+	},
+	Correction: {
+		Ticker: "FBTC", Description: "Fidelity", Date: time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC),
+		RetractedTotal: 1512.3, TotalAsset: 151230.4,
+	},
+}
+
+func TestRenderer_DefaultTemplates(t *testing.T) {
+	r, err := NewRenderer("")
+	if err != nil {
+		t.Fatalf("NewRenderer() error = %v", err)
+	}
+
+	for _, channel := range Channels {
+		for _, kind := range Kinds {
+			if !r.Has(channel, kind) {
+				continue
+			}
+			name := string(channel) + "_" + string(kind)
+			t.Run(name, func(t *testing.T) {
+				got, err := r.Render(channel, kind, testEvents[kind])
+				if err != nil {
+					t.Fatalf("Render() error = %v", err)
+				}
+
+				golden := filepath.Join("testdata", name+".golden")
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != string(want) {
+					t.Errorf("Render() got:\n%s\nwant:\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderer_CustomTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "x_flow.tmpl"), []byte("{{.Ticker}} {{comma .AssetDiff 2}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRenderer(dir)
+	if err != nil {
+		t.Fatalf("NewRenderer() error = %v", err)
+	}
+
+	got, _ := r.Render(X, Flow, testEvents[Flow])
+	if want := "IBIT 2,345.67"; got != want {
+		t.Errorf("Render() got = %q, want %q", got, want)
+	}
+
+	// Missing templates render nothing
+	got, err = r.Render(X, Error, testEvents[Error])
+	if got != "" || err != nil {
+		t.Errorf("Render() got = %q, %v, want empty", got, err)
+	}
+}
@@ -0,0 +1,218 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlueskyLimit is the maximum post length in graphemes, counted here as runes
+const BlueskyLimit = 300
+
+var cashtagRegex = regexp.MustCompile(`\$[A-Z]{2,5}\b`)
+
+type Bluesky struct {
+	// Host is the PDS, usually https://bsky.social
+	Host        string
+	Handle      string
+	AppPassword string
+	Client      *http.Client
+
+	mu         sync.Mutex
+	accessJwt  string
+	refreshJwt string
+	did        string
+}
+
+type blueskySession struct {
+	AccessJwt  string `json:"accessJwt"`
+	RefreshJwt string `json:"refreshJwt"`
+	Did        string `json:"did"`
+}
+
+type blueskyPost struct {
+	Type      string         `json:"$type"`
+	Text      string         `json:"text"`
+	CreatedAt string         `json:"createdAt"`
+	Facets    []blueskyFacet `json:"facets,omitempty"`
+}
+
+type blueskyFacet struct {
+	Index    blueskyIndex     `json:"index"`
+	Features []blueskyFeature `json:"features"`
+}
+
+type blueskyIndex struct {
+	ByteStart int `json:"byteStart"`
+	ByteEnd   int `json:"byteEnd"`
+}
+
+type blueskyFeature struct {
+	Type string `json:"$type"`
+	Tag  string `json:"tag"`
+}
+
+type blueskyRecord struct {
+	Repo       string      `json:"repo"`
+	Collection string      `json:"collection"`
+	Record     blueskyPost `json:"record"`
+}
+
+// cashtagFacets tags every $TICKER with its UTF-8 byte range
+func cashtagFacets(text string) []blueskyFacet {
+	var facets []blueskyFacet
+	for _, loc := range cashtagRegex.FindAllStringIndex(text, -1) {
+		facets = append(facets, blueskyFacet{
+			Index:    blueskyIndex{ByteStart: loc[0], ByteEnd: loc[1]},
+			Features: []blueskyFeature{{Type: "app.bsky.richtext.facet#tag", Tag: text[loc[0]:loc[1]]}},
+		})
+	}
+	return facets
+}
+
+func (b *Bluesky) client() *http.Client {
+	if b.Client == nil {
+		return http.DefaultClient
+	}
+	return b.Client
+}
+
+// session returns the cached session, creating one when needed
+func (b *Bluesky) session(ctx context.Context) (string, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.accessJwt != "" {
+		return b.accessJwt, b.did, nil
+	}
+
+	body, _ := json.Marshal(map[string]string{"identifier": b.Handle, "password": b.AppPassword})
+	var session blueskySession
+	if err := b.post(ctx, "com.atproto.server.createSession", "", body, &session); err != nil {
+		return "", "", err
+	}
+
+	b.accessJwt, b.refreshJwt, b.did = session.AccessJwt, session.RefreshJwt, session.Did
+
+	return b.accessJwt, b.did, nil
+}
+
+// refresh replaces an expired access token using the refresh token, logging
+// in again when that has expired too
+func (b *Bluesky) refresh(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	refreshJwt := b.refreshJwt
+	b.accessJwt, b.refreshJwt = "", ""
+	b.mu.Unlock()
+
+	if refreshJwt != "" {
+		var session blueskySession
+		err := b.post(ctx, "com.atproto.server.refreshSession", refreshJwt, nil, &session)
+		if err == nil {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.accessJwt, b.refreshJwt, b.did = session.AccessJwt, session.RefreshJwt, session.Did
+			return b.accessJwt, nil
+		}
+		log.Println("Bluesky session refresh error:", err)
+	}
+
+	accessJwt, _, err := b.session(ctx)
+	return accessJwt, err
+}
+
+// expired reports whether a call was rejected for an expired access token
+func expired(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ExpiredToken")
+}
+
+func (b *Bluesky) Notify(ctx context.Context, msg Message) error {
+	accessJwt, did, err := b.session(ctx)
+	if err != nil {
+		return err
+	}
+
+	text := Truncate(msg.Text, BlueskyLimit)
+	record := blueskyRecord{
+		Repo:       did,
+		Collection: "app.bsky.feed.post",
+		Record: blueskyPost{
+			Type:      "app.bsky.feed.post",
+			Text:      text,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			Facets:    cashtagFacets(text),
+		},
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	log.Println("Bluesky post:", strings.ReplaceAll(text, "\n", " "))
+
+	err = b.post(ctx, "com.atproto.repo.createRecord", accessJwt, body, nil)
+	if !expired(err) {
+		return err
+	}
+
+	// Access tokens expire after a couple of hours, renew it and post once more
+	if accessJwt, err = b.refresh(ctx); err != nil {
+		return err
+	}
+	err = b.post(ctx, "com.atproto.repo.createRecord", accessJwt, body, nil)
+	if expired(err) {
+		// Not permanent, the next attempt renews the session again
+		return fmt.Errorf("bluesky session expired: %v", err)
+	}
+	return err
+}
+
+// post calls an XRPC procedure and decodes the response into out when set
+func (b *Bluesky) post(ctx context.Context, method, accessJwt string, body []byte, out interface{}) error {
+	url := strings.TrimRight(b.Host, "/") + "/xrpc/" + method
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessJwt != "" {
+		req.Header.Set("Authorization", "Bearer "+accessJwt)
+	}
+
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if out != nil {
+			return json.Unmarshal(respBody, out)
+		}
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		err := fmt.Errorf("bluesky %s status %d: %s", method, resp.StatusCode, bytes.TrimSpace(respBody))
+		// ratelimit-reset is a unix timestamp
+		if reset, convErr := strconv.ParseInt(resp.Header.Get("ratelimit-reset"), 10, 64); convErr == nil {
+			return &RetryAfterError{After: time.Until(time.Unix(reset, 0)), Err: err}
+		}
+		return err
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("bluesky %s status %d: %s", method, resp.StatusCode, bytes.TrimSpace(respBody))
+	default:
+		return &PermanentError{Err: fmt.Errorf("bluesky %s status %d: %s", method, resp.StatusCode, bytes.TrimSpace(respBody))}
+	}
+}
@@ -0,0 +1,73 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBluesky_Notify(t *testing.T) {
+	var got blueskyRecord
+	sessions := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/xrpc/com.atproto.server.createSession", func(w http.ResponseWriter, r *http.Request) {
+		sessions++
+		w.Write([]byte(`{"accessJwt": "jwt", "did": "did:plc:test"}`))
+	})
+	mux.HandleFunc("/xrpc/com.atproto.repo.createRecord", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer jwt" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"uri": "at://did:plc:test/app.bsky.feed.post/1"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	b := &Bluesky{Host: server.URL, Handle: "test.bsky.social", AppPassword: "pw"}
+	text := "BlackRock $IBIT\n\n🚀 FLOW: 2,345.67 BTC, $121,600,123\n🏦 TOTAL Bitcoin in Trust: 128,765.4 $BTC"
+	for i := 0; i < 2; i++ {
+		if err := b.Notify(context.Background(), Message{Text: text}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	if sessions != 1 {
+		t.Errorf("createSession called %d times, want 1", sessions)
+	}
+	if got.Repo != "did:plc:test" || got.Record.Text != text {
+		t.Errorf("createRecord got = %+v", got)
+	}
+
+	var tags []string
+	for _, facet := range got.Record.Facets {
+		tags = append(tags, text[facet.Index.ByteStart:facet.Index.ByteEnd])
+	}
+	if strings.Join(tags, ",") != "$IBIT,$BTC" {
+		t.Errorf("facet ranges cover %v, want [$IBIT $BTC]", tags)
+	}
+}
+
+func TestBluesky_NotifyTruncates(t *testing.T) {
+	var got blueskyRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "createSession") {
+			w.Write([]byte(`{"accessJwt": "jwt", "did": "did:plc:test"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	b := &Bluesky{Host: server.URL}
+	if err := b.Notify(context.Background(), Message{Text: strings.Repeat("🚀", 400)}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if n := len([]rune(got.Record.Text)); n != BlueskyLimit {
+		t.Errorf("post length = %d, want %d", n, BlueskyLimit)
+	}
+}
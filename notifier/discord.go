@@ -0,0 +1,161 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type payload struct {
+	Username  string  `json:"username"`
+	AvatarURL string  `json:"avatar_url"`
+	Embeds    []embed `json:"embeds"`
+}
+
+type embed struct {
+	Title       string       `json:"title,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Description string       `json:"description"`
+	Color       int          `json:"color,omitempty"`
+	Timestamp   string       `json:"timestamp,omitempty"`
+	Footer      *embedFooter `json:"footer,omitempty"`
+	Image       *embedImage  `json:"image,omitempty"`
+	Fields      []embedField `json:"fields,omitempty"`
+}
+
+type embedFooter struct {
+	Text string `json:"text"`
+}
+
+type embedImage struct {
+	URL string `json:"url"`
+}
+
+type embedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// rateLimit is the body Discord returns with a 429
+type rateLimit struct {
+	RetryAfter float64 `json:"retry_after"`
+}
+
+type Discord struct {
+	WebhookURL     string
+	AvatarUsername string
+	AvatarURL      string
+	Client         *http.Client
+}
+
+// buildEmbed converts a message to the Discord embed format
+func buildEmbed(msg Message) embed {
+	blockEmbed := embed{Description: msg.Text}
+
+	if e := msg.Embed; e != nil {
+		blockEmbed.Title = e.Title
+		blockEmbed.URL = e.URL
+		blockEmbed.Color = e.Color
+		if !e.Timestamp.IsZero() {
+			blockEmbed.Timestamp = e.Timestamp.UTC().Format(time.RFC3339)
+		}
+		if e.Footer != "" {
+			blockEmbed.Footer = &embedFooter{Text: e.Footer}
+		}
+		for _, field := range e.Fields {
+			blockEmbed.Fields = append(blockEmbed.Fields, embedField{Name: field.Name, Value: field.Value, Inline: field.Inline})
+		}
+	}
+
+	if msg.Attachment != nil {
+		blockEmbed.Image = &embedImage{URL: "attachment://" + msg.Attachment.Name}
+	}
+
+	return blockEmbed
+}
+
+func (d *Discord) Notify(ctx context.Context, msg Message) error {
+	embeds := []embed{buildEmbed(msg)}
+	jsonReq := payload{Username: d.AvatarUsername, AvatarURL: d.AvatarURL, Embeds: embeds}
+
+	jsonStr, err := json.Marshal(jsonReq)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	log.Println("Discord POST:", string(jsonStr))
+
+	body := &bytes.Buffer{}
+	contentType := "application/json"
+	if msg.Attachment == nil {
+		body.Write(jsonStr)
+	} else {
+		// Files are sent as multipart with the JSON in payload_json
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("payload_json", string(jsonStr)); err != nil {
+			return &PermanentError{Err: err}
+		}
+		part, err := writer.CreateFormFile("files[0]", msg.Attachment.Name)
+		if err != nil {
+			return &PermanentError{Err: err}
+		}
+		part.Write(msg.Attachment.Data)
+		if err := writer.Close(); err != nil {
+			return &PermanentError{Err: err}
+		}
+		contentType = writer.FormDataContentType()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.WebhookURL, body)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	return checkDiscordResponse(resp, respBody)
+}
+
+func checkDiscordResponse(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err := fmt.Errorf("discord status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// Prefer the precise value in the body over the header
+		var limit rateLimit
+		if json.Unmarshal(body, &limit) == nil && limit.RetryAfter > 0 {
+			return &RetryAfterError{After: time.Duration(limit.RetryAfter * float64(time.Second)), Err: err}
+		}
+		if seconds, convErr := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); convErr == nil {
+			return &RetryAfterError{After: time.Duration(seconds * float64(time.Second)), Err: err}
+		}
+		return err
+	}
+
+	if resp.StatusCode >= 500 {
+		return err
+	}
+
+	return &PermanentError{Err: err}
+}
@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDiscord_Notify(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		header        string
+		body          string
+		wantErr       bool
+		wantPermanent bool
+		wantRetry     time.Duration
+	}{
+		{name: "ok", status: http.StatusNoContent},
+		{name: "rate limited body", status: http.StatusTooManyRequests, body: `{"retry_after": 1.5}`, wantErr: true, wantRetry: 1500 * time.Millisecond},
+		{name: "rate limited header", status: http.StatusTooManyRequests, header: "2", wantErr: true, wantRetry: 2 * time.Second},
+		{name: "server error", status: http.StatusBadGateway, wantErr: true},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: true, wantPermanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			d := &Discord{WebhookURL: server.URL}
+			err := d.Notify(context.Background(), Message{Text: "test"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if IsPermanent(err) != tt.wantPermanent {
+				t.Errorf("IsPermanent() = %v, want %v", IsPermanent(err), tt.wantPermanent)
+			}
+			if got, _ := RetryAfter(err); got != tt.wantRetry {
+				t.Errorf("RetryAfter() = %s, want %s", got, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestDiscord_NotifyEmbedAttachment(t *testing.T) {
+	var gotPayload, gotFile string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm() error = %v", err)
+		}
+		gotPayload = r.FormValue("payload_json")
+		if file, _, err := r.FormFile("files[0]"); err == nil {
+			data, _ := io.ReadAll(file)
+			gotFile = string(data)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	d := &Discord{WebhookURL: server.URL}
+	msg := Message{
+		Text: "summary",
+		Embed: &Embed{
+			Title:     "BlackRock (IBIT)",
+			Color:     0x2ECC71,
+			Timestamp: time.Date(2024, 2, 16, 21, 0, 0, 0, time.UTC),
+			Footer:    "Source: ishares.com",
+			Fields:    []Field{{Name: "Change", Value: "1 BTC", Inline: true}},
+		},
+		Attachment: &Attachment{Name: "chart.png", Data: []byte("png")},
+	}
+	if err := d.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	want := `{"username":"","avatar_url":"","embeds":[{"title":"BlackRock (IBIT)","description":"summary","color":3066993,"timestamp":"2024-02-16T21:00:00Z","footer":{"text":"Source: ishares.com"},"image":{"url":"attachment://chart.png"},"fields":[{"name":"Change","value":"1 BTC","inline":true}]}]}`
+	if gotPayload != want {
+		t.Errorf("payload_json got:\n%s\nwant:\n%s", gotPayload, want)
+	}
+	if gotFile != "png" {
+		t.Errorf("attachment got = %q", gotFile)
+	}
+}
@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MastodonLimit is the default status length on most instances
+const MastodonLimit = 500
+
+type Mastodon struct {
+	// InstanceURL such as https://mastodon.social
+	InstanceURL string
+	AccessToken string
+	// Limit overrides MastodonLimit for instances with a different maximum
+	Limit  int
+	Client *http.Client
+}
+
+func (m *Mastodon) Notify(ctx context.Context, msg Message) error {
+	limit := m.Limit
+	if limit == 0 {
+		limit = MastodonLimit
+	}
+	text := Truncate(msg.Text, limit)
+
+	form := url.Values{"status": {text}}
+	endpoint := strings.TrimRight(m.InstanceURL, "/") + "/api/v1/statuses"
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+
+	// Mastodon drops repeated requests with the same key, guarding against retries after a lost response
+	sum := sha256.Sum256([]byte(text))
+	req.Header.Set("Idempotency-Key", hex.EncodeToString(sum[:]))
+
+	log.Println("Mastodon status:", strings.ReplaceAll(text, "\n", " "))
+
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("mastodon status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// X-RateLimit-Reset is an ISO 8601 timestamp
+		if reset, parseErr := time.Parse(time.RFC3339, resp.Header.Get("X-RateLimit-Reset")); parseErr == nil {
+			return &RetryAfterError{After: time.Until(reset), Err: err}
+		}
+		return err
+	case resp.StatusCode >= 500:
+		return err
+	default:
+		return &PermanentError{Err: err}
+	}
+}
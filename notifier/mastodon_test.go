@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMastodon_Notify(t *testing.T) {
+	var gotStatus, gotAuth, gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statuses" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		gotStatus = r.FormValue("status")
+		gotAuth = r.Header.Get("Authorization")
+		gotKey = r.Header.Get("Idempotency-Key")
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer server.Close()
+
+	m := &Mastodon{InstanceURL: server.URL, AccessToken: "token", Limit: 10}
+	if err := m.Notify(context.Background(), Message{Text: "Grayscale $GBTC outflow"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if gotStatus != "Grayscale…" {
+		t.Errorf("status = %q", gotStatus)
+	}
+	if gotAuth != "Bearer token" || gotKey == "" {
+		t.Errorf("headers Authorization = %q, Idempotency-Key = %q", gotAuth, gotKey)
+	}
+}
+
+func TestMastodon_NotifyRateLimited(t *testing.T) {
+	reset := time.Now().Add(5 * time.Minute).UTC()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Reset", reset.Format(time.RFC3339))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	m := &Mastodon{InstanceURL: server.URL}
+	err := m.Notify(context.Background(), Message{Text: "test"})
+	wait, ok := RetryAfter(err)
+	if !ok || wait < 4*time.Minute || wait > 5*time.Minute {
+		t.Errorf("RetryAfter() = %s, %v, want about 5m", wait, ok)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Package notifier delivers rendered messages to the output channels.
+
+Notifiers report whether a failed delivery is worth retrying so the outbox can
+schedule the next attempt.
+*/
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a rendered notification for one channel
+type Message struct {
+	Text string
+
+	// Embed is only used by Discord. Text becomes its description.
+	Embed *Embed `json:",omitempty"`
+
+	// Attachment is an optional file sent with the message
+	Attachment *Attachment `json:",omitempty"`
+}
+
+type Embed struct {
+	Title     string
+	URL       string
+	Color     int
+	Timestamp time.Time
+	Footer    string
+	Fields    []Field
+}
+
+type Field struct {
+	Name   string
+	Value  string
+	Inline bool
+}
+
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// RetryAfterError is returned when the channel asks us to wait before retrying
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("retry after %s: %v", e.After, e.Err)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// PermanentError is returned when retrying the same message can not succeed
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("permanent: %v", e.Err)
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether err is a PermanentError
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// RetryAfter returns the wait requested by the channel, if any
+func RetryAfter(err error) (time.Duration, bool) {
+	var retry *RetryAfterError
+	if errors.As(err, &retry) {
+		return retry.After, true
+	}
+	return 0, false
+}
+
+// Truncate shortens text to at most limit runes, ending with an ellipsis when cut
+func Truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
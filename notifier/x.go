@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michimani/gotwi"
+	"github.com/michimani/gotwi/tweet/managetweet"
+	gotwiTypes "github.com/michimani/gotwi/tweet/managetweet/types"
+)
+
+type X struct {
+	OAuthToken       string
+	OAuthTokenSecret string
+
+	mu     sync.Mutex
+	client *gotwi.Client
+}
+
+// getClient creates the gotwi client once and reuses it for every post
+func (x *X) getClient() (*gotwi.Client, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.client != nil {
+		return x.client, nil
+	}
+
+	in := &gotwi.NewClientInput{
+		AuthenticationMethod: gotwi.AuthenMethodOAuth1UserContext,
+		OAuthToken:           x.OAuthToken,
+		OAuthTokenSecret:     x.OAuthTokenSecret,
+	}
+
+	c, err := gotwi.NewClient(in)
+	if err != nil {
+		return nil, err
+	}
+	x.client = c
+
+	return c, nil
+}
+
+func (x *X) Notify(ctx context.Context, msg Message) error {
+	c, err := x.getClient()
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	p := &gotwiTypes.CreateInput{
+		Text: gotwi.String(msg.Text),
+	}
+
+	// Replace newline characters with spaces
+	logStr := strings.ReplaceAll(msg.Text, "\n", " ")
+	log.Println("X Tweet:", logStr)
+
+	_, err = managetweet.Create(ctx, c, p)
+	if err != nil {
+		return classifyXError(err)
+	}
+
+	return nil
+}
+
+func classifyXError(err error) error {
+	var gotwiErr *gotwi.GotwiError
+	if !errors.As(err, &gotwiErr) || !gotwiErr.OnAPI {
+		return err
+	}
+
+	switch {
+	case gotwiErr.StatusCode == http.StatusTooManyRequests:
+		if info := gotwiErr.RateLimitInfo; info != nil && info.ResetAt != nil {
+			return &RetryAfterError{After: time.Until(*info.ResetAt), Err: err}
+		}
+		return err
+	case gotwiErr.StatusCode >= 500:
+		return err
+	default:
+		return &PermanentError{Err: err}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/notifier"
+	"github.com/jyap808/btcEtfScrape/outbox"
+	"github.com/jyap808/btcEtfScrape/revision"
+)
+
+// notify renders the event for each channel and posts every non-empty message
+func notify(kind message.Kind, event message.Event) {
+	discordMsg, err := templates().Render(message.Discord, kind, event)
+	if err != nil {
+		log.Printf("Render %s %s error: %v", message.Discord, kind, err)
+	} else if discordMsg != "" {
+		msg := notifier.Message{Text: discordMsg, Embed: discordEmbed(kind, event)}
+		if kind == message.Summary && summaryImage != "" {
+			data, err := os.ReadFile(summaryImage)
+			if err != nil {
+				log.Println("Summary image error:", err)
+			} else {
+				msg.Attachment = &notifier.Attachment{Name: filepath.Base(summaryImage), Data: data}
+			}
+		}
+		deliver(string(message.Discord), kind, event, msg, false)
+	}
+
+	// Reporting threshold check. Get the absolute difference
+	suppressed := false
+	if kind == message.Flow || kind == message.Override {
+		absAssetDiff := math.Abs(event.AssetDiff)
+		suppressed = absAssetDiff <= conf().FundMinBitcoinDiff(event.Ticker)
+	}
+	if suppressed && !dryRun {
+		return
+	}
+
+	xMsg, err := templates().Render(message.X, kind, event)
+	if err != nil {
+		log.Printf("Render %s %s error: %v", message.X, kind, err)
+	} else if xMsg != "" {
+		// Bluesky and Mastodon post the same content as X
+		for _, channel := range socialChannels() {
+			deliver(channel, kind, event, notifier.Message{Text: xMsg}, suppressed)
+		}
+	}
+}
+
+// deliver queues the message, or only writes it out in dry run mode
+func deliver(channel string, kind message.Kind, event message.Event, msg notifier.Message, suppressed bool) {
+	// Funds can be limited to some channels
+	if event.Ticker != "" && !conf().Funds[event.Ticker].PostsTo(channel) {
+		return
+	}
+
+	if dryRun {
+		writeDryRun(channel, kind, event, msg, suppressed)
+		return
+	}
+
+	enqueue(channel, kind, event, msg)
+}
+
+var dryRunMu sync.Mutex
+
+// writeDryRun records a message that would have been sent
+func writeDryRun(channel string, kind message.Kind, event message.Event, msg notifier.Message, suppressed bool) {
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+
+	fmt.Fprintf(dryRunWriter, "=== DRY RUN %s channel=%s kind=%s ticker=%s suppressed=%t\n",
+		time.Now().Format(time.RFC3339), channel, kind, event.Ticker, suppressed)
+	if msg.Embed != nil {
+		fmt.Fprintf(dryRunWriter, "[embed] title=%q url=%q color=#%06X footer=%q\n", msg.Embed.Title, msg.Embed.URL, msg.Embed.Color, msg.Embed.Footer)
+		for _, field := range msg.Embed.Fields {
+			fmt.Fprintf(dryRunWriter, "[field] %s: %s\n", field.Name, field.Value)
+		}
+	}
+	if msg.Attachment != nil {
+		fmt.Fprintf(dryRunWriter, "[attachment] %s (%d bytes)\n", msg.Attachment.Name, len(msg.Attachment.Data))
+	}
+	fmt.Fprintf(dryRunWriter, "%s\n\n", msg.Text)
+}
+
+const (
+	channelBluesky  = "bluesky"
+	channelMastodon = "mastodon"
+)
+
+// socialChannels returns the channels that receive the X rendered message
+func socialChannels() []string {
+	channels := []string{string(message.X)}
+	if blueskyHandle != "" {
+		channels = append(channels, channelBluesky)
+	}
+	if mastodonURL != "" {
+		channels = append(channels, channelMastodon)
+	}
+	return channels
+}
+
+const (
+	colorInflow  = 0x2ECC71
+	colorOutflow = 0xE74C3C
+	colorAlert   = 0xF1C40F
+)
+
+// discordEmbed builds the title, link and fields shown around the rendered text
+func discordEmbed(kind message.Kind, event message.Event) *notifier.Embed {
+	e := &notifier.Embed{Timestamp: time.Now()}
+
+	if kind == message.Summary {
+		e.Title = "Bitcoin ETF daily summary"
+		e.Fields = flowFields(event)
+		e.Color = flowColor(event.AssetDiff)
+		e.Footer = "Source: issuer holdings"
+		return e
+	}
+
+	detail := details()[event.Ticker]
+	e.Title = fmt.Sprintf("%s (%s)", detail.Description, event.Ticker)
+	e.URL = detail.URL
+	if u, err := url.Parse(detail.URL); err == nil && u.Host != "" {
+		e.Footer = "Source: " + u.Host
+	}
+
+	switch kind {
+	case message.Flow, message.Override:
+		e.Color = flowColor(event.AssetDiff)
+		e.Fields = append(flowFields(event),
+			notifier.Field{Name: "Total", Value: humanize.CommafWithDigits(event.TotalAsset, 1) + " BTC", Inline: true},
+			notifier.Field{Name: "Reference price", Value: priceValue(event), Inline: true},
+		)
+		if event.HasNavFlow {
+			e.Fields = append(e.Fields, notifier.Field{Name: "NAV Flow", Value: "$" + humanize.CommafWithDigits(event.NavFlow, 0), Inline: true})
+		}
+		if event.PriceSource != "" {
+			e.Footer += ", " + event.PriceSource
+		}
+	case message.Initialization:
+		e.Fields = []notifier.Field{
+			{Name: "Total", Value: humanize.CommafWithDigits(event.TotalAsset, 1) + " BTC", Inline: true},
+		}
+	case message.Error:
+		e.Color = colorOutflow
+	case message.Alert, message.Correction:
+		e.Color = colorAlert
+	}
+
+	return e
+}
+
+// priceValue shows the price with the provider and fixing it came from
+func priceValue(event message.Event) string {
+	value := "$" + humanize.CommafWithDigits(event.Price, 2)
+	if event.PriceSource != "" {
+		value += fmt.Sprintf(" (%s %s)", event.PriceSource, event.PriceDate.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	return value
+}
+
+func flowFields(event message.Event) []notifier.Field {
+	return []notifier.Field{
+		{Name: "Change", Value: humanize.CommafWithDigits(event.AssetDiff, 2) + " BTC", Inline: true},
+		{Name: "USD Flow", Value: "$" + humanize.CommafWithDigits(event.FlowDiff, 0), Inline: true},
+	}
+}
+
+func flowColor(assetDiff float64) int {
+	if assetDiff < 0 {
+		return colorOutflow
+	}
+	return colorInflow
+}
+
+// enqueue adds a rendered message to the outbox, deduplicated by its trade
+// date and total
+func enqueue(channel string, kind message.Kind, event message.Event, msg notifier.Message) {
+	post := postFor(kind, event)
+	_, err := notifications.Enqueue(channel, post.Kind, event.Ticker, post.TradeDate, post.Version, msg)
+	if err != nil {
+		log.Printf("Outbox enqueue %s %s error: %v", channel, event.Ticker, err)
+	}
+}
+
+// postFor identifies the outbox entries an event is queued under
+func postFor(kind message.Kind, event message.Event) *revision.Post {
+	// Undated results are keyed by the day they were seen
+	tradeDate := event.Date
+	if tradeDate.IsZero() {
+		tradeDate = time.Now()
+	}
+
+	// Alerts for different rules on the same day are separate messages
+	kindKey := string(kind)
+	if event.Rule != "" {
+		kindKey += ":" + event.Rule
+	}
+
+	// A second total for the same day, such as an issuer revision, is a new post
+	version := ""
+	if event.TotalAsset != 0 {
+		version = strconv.FormatFloat(event.TotalAsset, 'f', -1, 64)
+	}
+
+	return &revision.Post{Kind: kindKey, TradeDate: tradeDate.Format("2006-01-02"), Version: version}
+}
+
+// newOutbox opens the outbox and registers the channel notifiers
+func newOutbox(path string) (*outbox.Outbox, error) {
+	o, err := outbox.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	registerDiscord(o, conf())
+	o.Register(string(message.X), &notifier.X{
+		OAuthToken:       os.Getenv(OAuthTokenEnvKeyName),
+		OAuthTokenSecret: os.Getenv(OAuthTokenSecretEnvKeyName),
+	})
+
+	if blueskyHandle != "" {
+		o.Register(channelBluesky, &notifier.Bluesky{
+			Host:        blueskyHost,
+			Handle:      blueskyHandle,
+			AppPassword: os.Getenv(BlueskyAppPasswordEnvKeyName),
+			Client:      &http.Client{Timeout: 30 * time.Second},
+		})
+	}
+
+	if mastodonURL != "" {
+		o.Register(channelMastodon, &notifier.Mastodon{
+			InstanceURL: mastodonURL,
+			AccessToken: os.Getenv(MastodonAccessTokenEnvKeyName),
+			Client:      &http.Client{Timeout: 30 * time.Second},
+		})
+	}
+
+	return o, nil
+}
+
+// registerDiscord sets the Discord notifier from the config, replacing any
+// previous one
+func registerDiscord(o *outbox.Outbox, c *config.Config) {
+	o.Register(string(message.Discord), &notifier.Discord{
+		WebhookURL:     c.Discord.WebhookURL,
+		AvatarUsername: c.Discord.AvatarUsername,
+		AvatarURL:      c.Discord.AvatarURL,
+		Client:         &http.Client{Timeout: 30 * time.Second},
+	})
+}
+
+// handleOutboxFailed lists the notifications that could not be delivered
+func handleOutboxFailed(w http.ResponseWriter, r *http.Request) {
+	if notifications == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "outbox disabled in dry run")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notifications.Entries(outbox.Failed))
+}
+
+func handleOutboxRequeue(w http.ResponseWriter, r *http.Request) {
+	if notifications == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "outbox disabled in dry run")
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if err := notifications.Requeue(key); err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	audit(r, "outbox requeue", "", nil, key)
+	log.Printf("Outbox requeue %s by %s", key, actorFrom(r))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "requeued", "key": key})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/outbox"
+)
+
+func TestNotify_DryRun(t *testing.T) {
+	o, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, _ = message.NewRenderer("")
+	var output bytes.Buffer
+	previousWriter := dryRunWriter
+	dryRun, dryRunWriter, notifications = true, &output, o
+	defer func() {
+		renderer, dryRun, dryRunWriter, notifications = nil, false, previousWriter, nil
+	}()
+
+	flow := func(assetDiff float64) message.Event {
+		return message.Event{Ticker: "IBIT", Description: "BlackRock", Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			AssetDiff: assetDiff, TotalAsset: 200000 + assetDiff, FlowDiff: assetDiff * 60000, Price: 60000}
+	}
+
+	notify(message.Flow, flow(2500))
+	notify(message.Flow, flow(0.5))
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "discord flow", want: "channel=discord kind=flow ticker=IBIT suppressed=false"},
+		{name: "discord embed", want: "[embed] title=\"BlackRock (IBIT)\""},
+		{name: "x flow", want: "channel=x kind=flow ticker=IBIT suppressed=false"},
+		{name: "x below the threshold", want: "channel=x kind=flow ticker=IBIT suppressed=true"},
+		{name: "rendered text", want: "2,500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(output.String(), tt.want) {
+				t.Errorf("dry run output does not contain %q:\n%s", tt.want, output.String())
+			}
+		})
+	}
+
+	if entries := o.Entries(""); len(entries) != 0 {
+		t.Errorf("dry run queued %d notifications: %+v", len(entries), entries)
+	}
+}
+
+func TestNotifyOps_Dedup(t *testing.T) {
+	renderer, _ = message.NewRenderer("")
+	var output bytes.Buffer
+	previousWriter := dryRunWriter
+	dryRun, dryRunWriter = true, &output
+	defer func() {
+		renderer, dryRun, dryRunWriter = nil, false, previousWriter
+	}()
+
+	sent := func() int {
+		return strings.Count(output.String(), "kind=alert ticker=BTCW")
+	}
+
+	notifyOps("test-rule", message.Event{Ticker: "BTCW"}, "source broken")
+	notifyOps("test-rule", message.Event{Ticker: "BTCW"}, "source broken")
+	// Sent to the default ops channel, discord
+	if got := sent(); got != 1 {
+		t.Fatalf("sent %d alerts for a repeated alert, want 1:\n%s", got, output.String())
+	}
+
+	notifyOps("test-rule", message.Event{Ticker: "BTCW"}, "source broken differently")
+	if got := sent(); got != 2 {
+		t.Errorf("sent %d alerts after a new reason, want 2", got)
+	}
+}
@@ -0,0 +1,347 @@
+/*
+Package outbox persists pending notifications and delivers them with retries.
+
+Every message is keyed by channel, kind, ticker, trade date and version. A
+key that has already been queued is never queued again, so a restart can not
+double post, while a revised total for the same trade date is still posted.
+*/
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/notifier"
+)
+
+type Status string
+
+const (
+	Pending  Status = "pending"
+	Sent     Status = "sent"
+	Failed   Status = "failed"
+	Canceled Status = "canceled"
+)
+
+type Entry struct {
+	Key         string           `json:"key"`
+	Channel     string           `json:"channel"`
+	Kind        string           `json:"kind"`
+	Ticker      string           `json:"ticker"`
+	TradeDate   string           `json:"tradeDate"`
+	Version     string           `json:"version,omitempty"`
+	Message     notifier.Message `json:"message"`
+	Status      Status           `json:"status"`
+	Attempts    int              `json:"attempts"`
+	NextAttempt time.Time        `json:"nextAttempt"`
+	LastError   string           `json:"lastError,omitempty"`
+	Created     time.Time        `json:"created"`
+	Updated     time.Time        `json:"updated"`
+}
+
+type Outbox struct {
+	path      string
+	mu        sync.Mutex
+	entries   map[string]*Entry
+	notifiers map[string]notifier.Notifier
+	wake      chan struct{}
+
+	// MaxAttempts before an entry is marked failed
+	MaxAttempts int
+	// Backoff for the first retry, doubled on every attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Sent and canceled entries older than this are pruned
+	Retention time.Duration
+}
+
+// Key builds the dedup key for a message. The version, such as the total
+// posted, tells apart messages for the same trade date and may be empty.
+func Key(channel, kind, ticker, tradeDate, version string) string {
+	key := fmt.Sprintf("%s/%s/%s/%s", channel, kind, ticker, tradeDate)
+	if version != "" {
+		key += "/" + version
+	}
+	return key
+}
+
+// Open loads the outbox stored at path, starting empty if it does not exist
+func Open(path string) (*Outbox, error) {
+	o := &Outbox{
+		path:        path,
+		entries:     map[string]*Entry{},
+		notifiers:   map[string]notifier.Notifier{},
+		wake:        make(chan struct{}, 1),
+		MaxAttempts: 10,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  time.Hour,
+		Retention:   30 * 24 * time.Hour,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		o.entries[entry.Key] = entry
+	}
+
+	return o, nil
+}
+
+// Register sets the notifier used to deliver a channel
+func (o *Outbox) Register(channel string, n notifier.Notifier) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.notifiers[channel] = n
+}
+
+// Enqueue stores a new message. It returns false when the key was already queued.
+func (o *Outbox) Enqueue(channel, kind, ticker, tradeDate, version string, msg notifier.Message) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := Key(channel, kind, ticker, tradeDate, version)
+	if _, ok := o.entries[key]; ok {
+		log.Println("Outbox duplicate skipped:", key)
+		return false, nil
+	}
+
+	now := time.Now()
+	o.entries[key] = &Entry{
+		Key:         key,
+		Channel:     channel,
+		Kind:        kind,
+		Ticker:      ticker,
+		TradeDate:   tradeDate,
+		Version:     version,
+		Message:     msg,
+		Status:      Pending,
+		NextAttempt: now,
+		Created:     now,
+		Updated:     now,
+	}
+
+	if err := o.save(); err != nil {
+		return true, err
+	}
+
+	o.signal()
+
+	return true, nil
+}
+
+// Requeue moves a failed entry back to pending
+func (o *Outbox) Requeue(key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, ok := o.entries[key]
+	if !ok {
+		return fmt.Errorf("outbox entry %q not found", key)
+	}
+	if entry.Status != Failed {
+		return fmt.Errorf("outbox entry %q is %s", key, entry.Status)
+	}
+
+	entry.Status = Pending
+	entry.Attempts = 0
+	entry.NextAttempt = time.Now()
+	entry.Updated = time.Now()
+
+	if err := o.save(); err != nil {
+		return err
+	}
+
+	o.signal()
+
+	return nil
+}
+
+// Cancel stops a pending entry from being delivered
+func (o *Outbox) Cancel(key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, ok := o.entries[key]
+	if !ok {
+		return fmt.Errorf("outbox entry %q not found", key)
+	}
+	if entry.Status != Pending {
+		return fmt.Errorf("outbox entry %q is %s", key, entry.Status)
+	}
+
+	entry.Status = Canceled
+	entry.Updated = time.Now()
+
+	return o.save()
+}
+
+// Entries returns a copy of the entries with the given status, or every entry
+// when status is empty, oldest first
+func (o *Outbox) Entries(status Status) []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var entries []Entry
+	for _, entry := range o.entries {
+		if status == "" || entry.Status == status {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+
+	return entries
+}
+
+// Run delivers pending entries until ctx is done
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		wait := o.deliverDue(ctx)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-o.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// deliverDue attempts every entry that is due and returns the time until the next one
+func (o *Outbox) deliverDue(ctx context.Context) time.Duration {
+	for _, entry := range o.Entries(Pending) {
+		if time.Now().Before(entry.NextAttempt) {
+			continue
+		}
+
+		o.mu.Lock()
+		n := o.notifiers[entry.Channel]
+		o.mu.Unlock()
+		if n == nil {
+			continue
+		}
+
+		err := n.Notify(ctx, entry.Message)
+		o.record(entry.Key, err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.prune()
+
+	wait := o.MaxBackoff
+	for _, entry := range o.entries {
+		if entry.Status != Pending || o.notifiers[entry.Channel] == nil {
+			continue
+		}
+		if until := time.Until(entry.NextAttempt); until < wait {
+			wait = until
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait
+}
+
+// record stores the result of a delivery attempt. An entry canceled, pruned
+// or requeued while it was being delivered is left as it is.
+func (o *Outbox) record(key string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, ok := o.entries[key]
+	if !ok || entry.Status != Pending {
+		log.Printf("Outbox %s changed during delivery, result not recorded: %v", key, err)
+		return
+	}
+	entry.Attempts++
+	entry.Updated = time.Now()
+
+	switch {
+	case err == nil:
+		entry.Status = Sent
+		entry.LastError = ""
+	case notifier.IsPermanent(err) || entry.Attempts >= o.MaxAttempts:
+		entry.Status = Failed
+		entry.LastError = err.Error()
+		log.Printf("Outbox %s failed after %d attempts: %v", key, entry.Attempts, err)
+	default:
+		entry.LastError = err.Error()
+		wait, ok := notifier.RetryAfter(err)
+		if !ok {
+			wait = o.Backoff << (entry.Attempts - 1)
+			if wait > o.MaxBackoff || wait <= 0 {
+				wait = o.MaxBackoff
+			}
+		}
+		entry.NextAttempt = time.Now().Add(wait)
+		log.Printf("Outbox %s attempt %d error, retry in %s: %v", key, entry.Attempts, wait, err)
+	}
+
+	if err := o.save(); err != nil {
+		log.Println("Outbox save error:", err)
+	}
+}
+
+// prune drops sent and canceled entries past the retention period
+func (o *Outbox) prune() {
+	for key, entry := range o.entries {
+		if (entry.Status == Sent || entry.Status == Canceled) && time.Since(entry.Updated) > o.Retention {
+			delete(o.entries, key)
+		}
+	}
+}
+
+func (o *Outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// save writes the outbox atomically. Callers hold o.mu.
+func (o *Outbox) save() error {
+	entries := make([]*Entry, 0, len(o.entries))
+	for _, entry := range o.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, o.path)
+}
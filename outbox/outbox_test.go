@@ -0,0 +1,196 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/notifier"
+)
+
+type fakeNotifier struct {
+	errs []error
+	sent []notifier.Message
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, msg notifier.Message) error {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestOutbox_DedupAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+
+	o, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &fakeNotifier{}
+	o.Register("discord", n)
+
+	if ok, _ := o.Enqueue("discord", "flow", "IBIT", "2024-02-16", "", notifier.Message{Text: "a"}); !ok {
+		t.Fatal("Enqueue() first message skipped")
+	}
+	o.deliverDue(context.Background())
+	if len(n.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(n.sent))
+	}
+
+	// Reopen as if the process restarted
+	o, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := o.Enqueue("discord", "flow", "IBIT", "2024-02-16", "", notifier.Message{Text: "a"}); ok {
+		t.Error("Enqueue() duplicate accepted after restart")
+	}
+	if ok, _ := o.Enqueue("discord", "flow", "IBIT", "2024-02-20", "", notifier.Message{Text: "b"}); !ok {
+		t.Error("Enqueue() new trade date skipped")
+	}
+	if ok, _ := o.Enqueue("discord", "flow", "IBIT", "2024-02-16", "1234.5", notifier.Message{Text: "c"}); !ok {
+		t.Error("Enqueue() new version of a trade date skipped")
+	}
+}
+
+func TestOutbox_Retry(t *testing.T) {
+	o, err := Open(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Backoff = time.Millisecond
+	n := &fakeNotifier{errs: []error{
+		&notifier.RetryAfterError{After: time.Hour, Err: errors.New("429")},
+	}}
+	o.Register("x", n)
+	o.Enqueue("x", "flow", "GBTC", "2024-02-16", "", notifier.Message{Text: "a"})
+
+	wait := o.deliverDue(context.Background())
+	if wait < 59*time.Minute {
+		t.Errorf("deliverDue() wait = %s, want about 1h from retry after", wait)
+	}
+	if pending := o.Entries(Pending); len(pending) != 1 || pending[0].Attempts != 1 {
+		t.Fatalf("Entries(Pending) = %+v", pending)
+	}
+
+	// A permanent error fails the entry immediately
+	o.entries[Key("x", "flow", "GBTC", "2024-02-16", "")].NextAttempt = time.Now()
+	n.errs = []error{&notifier.PermanentError{Err: errors.New("403")}}
+	o.deliverDue(context.Background())
+	if failed := o.Entries(Failed); len(failed) != 1 {
+		t.Fatalf("Entries(Failed) = %+v", failed)
+	}
+
+	if err := o.Requeue(Key("x", "flow", "GBTC", "2024-02-16", "")); err != nil {
+		t.Fatal(err)
+	}
+	o.deliverDue(context.Background())
+	if len(n.sent) != 1 || len(o.Entries(Sent)) != 1 {
+		t.Errorf("requeued entry not delivered")
+	}
+}
+
+func TestOutbox_BlueskyExpiredToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		refresh    int
+		wantStatus Status
+	}{
+		{name: "session refreshed", refresh: http.StatusOK, wantStatus: Sent},
+		{name: "refresh unavailable", refresh: http.StatusBadGateway, wantStatus: Pending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A stand-in PDS whose logins hand out an already expired access token
+			mux := http.NewServeMux()
+			mux.HandleFunc("/xrpc/com.atproto.server.createSession", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"accessJwt": "expired", "refreshJwt": "refresh", "did": "did:plc:test"}`))
+			})
+			mux.HandleFunc("/xrpc/com.atproto.server.refreshSession", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer refresh" {
+					t.Errorf("refreshSession Authorization = %q", r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.refresh)
+				w.Write([]byte(`{"accessJwt": "fresh", "refreshJwt": "refresh2", "did": "did:plc:test"}`))
+			})
+			mux.HandleFunc("/xrpc/com.atproto.repo.createRecord", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer fresh" {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error": "ExpiredToken", "message": "Token has expired"}`))
+					return
+				}
+				w.Write([]byte(`{"uri": "at://did:plc:test/app.bsky.feed.post/1"}`))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			o, err := Open(filepath.Join(t.TempDir(), "outbox.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			o.Register("bluesky", &notifier.Bluesky{Host: server.URL, Handle: "test.bsky.social", AppPassword: "pw"})
+			o.Enqueue("bluesky", "flow", "IBIT", "2024-02-16", "", notifier.Message{Text: "$IBIT flow"})
+
+			o.deliverDue(context.Background())
+			if entries := o.Entries(""); len(entries) != 1 || entries[0].Status != tt.wantStatus {
+				t.Errorf("entries = %+v, want %s", entries, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestOutbox_Cancel(t *testing.T) {
+	o, err := Open(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &fakeNotifier{}
+	o.Register("discord", n)
+	o.Enqueue("discord", "flow", "IBIT", "2024-02-16", "", notifier.Message{Text: "a"})
+
+	key := Key("discord", "flow", "IBIT", "2024-02-16", "")
+	if err := o.Cancel(key); err != nil {
+		t.Fatal(err)
+	}
+	o.deliverDue(context.Background())
+	if len(n.sent) != 0 || len(o.Entries(Canceled)) != 1 {
+		t.Errorf("canceled entry delivered: sent %d", len(n.sent))
+	}
+
+	if err := o.Cancel(key); err == nil {
+		t.Error("Cancel() of a canceled entry succeeded")
+	}
+}
+
+// cancelingNotifier cancels the entry it is delivering, as a rollback can
+type cancelingNotifier struct {
+	o   *Outbox
+	key string
+}
+
+func (c *cancelingNotifier) Notify(ctx context.Context, msg notifier.Message) error {
+	return c.o.Cancel(c.key)
+}
+
+func TestOutbox_CancelDuringDelivery(t *testing.T) {
+	o, err := Open(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key("discord", "flow", "IBIT", "2024-02-16", "")
+	o.Register("discord", &cancelingNotifier{o: o, key: key})
+	o.Enqueue("discord", "flow", "IBIT", "2024-02-16", "", notifier.Message{Text: "a"})
+
+	o.deliverDue(context.Background())
+	if entries := o.Entries(""); len(entries) != 1 || entries[0].Status != Canceled {
+		t.Errorf("entries = %+v, want the entry left canceled", entries)
+	}
+}
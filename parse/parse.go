@@ -0,0 +1,171 @@
+/*
+Package parse reads the numbers and dates issuers publish.
+
+Issuer pages format values for people: thousands separators, currency symbols,
+units, parentheses for negatives and non-breaking spaces. The parsers here
+accept those formats and nothing else, returning an *Error naming the fund,
+field and raw input instead of silently returning zero.
+*/
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrEmpty  = errors.New("empty value")
+	ErrSyntax = errors.New("not a number")
+	ErrDate   = errors.New("not a date")
+	// ErrMissing is a value not found in the issuer's data at all
+	ErrMissing = errors.New("missing value")
+)
+
+// Error is a value that could not be parsed
+type Error struct {
+	Fund  string
+	Field string
+	Raw   string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: cannot parse %q: %v", e.Fund, e.Field, e.Raw, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Units and currency symbols that may surround a number, matched case
+// insensitively
+var units = []string{"$", "usd", "btc", "xbt", "bitcoin", "shares"}
+
+// Magnitude suffixes such as 1.2M
+var magnitudes = map[string]float64{"k": 1e3, "m": 1e6, "mm": 1e6, "b": 1e9, "bn": 1e9}
+
+// A number with optional thousands separators in groups of three
+var numberPattern = regexp.MustCompile(`^(\d{1,3}(,\d{3})+|\d+)(\.\d+)?$|^\.\d+$`)
+
+// A number using spaces as thousands separators, such as "1 234 567.8"
+var spacedPattern = regexp.MustCompile(`^\d{1,3}( \d{3})+(\.\d+)?$`)
+
+// Number parses an issuer formatted number such as "1,234.5", "$12.34",
+// "(1,000)", "-2.5 BTC" or "1.2M"
+func Number(fund, field, raw string) (float64, error) {
+	fail := func(err error) (float64, error) {
+		return 0, &Error{Fund: fund, Field: field, Raw: raw, Err: err}
+	}
+
+	s := normalizeSpace(raw)
+	if s == "" {
+		return fail(ErrEmpty)
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "\u2212") {
+		if negative {
+			return fail(ErrSyntax)
+		}
+		negative = true
+		s = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(s, "-"), "\u2212"))
+	}
+
+	s = trimUnits(s)
+
+	multiplier := 1.0
+	if i := strings.LastIndexFunc(s, unicode.IsDigit); i >= 0 && i < len(s)-1 {
+		suffix := strings.ToLower(strings.TrimSpace(s[i+1:]))
+		m, ok := magnitudes[suffix]
+		if !ok {
+			return fail(ErrSyntax)
+		}
+		multiplier = m
+		s = s[:i+1]
+	}
+
+	if spacedPattern.MatchString(s) {
+		s = strings.ReplaceAll(s, " ", ",")
+	}
+	if !numberPattern.MatchString(s) {
+		return fail(ErrSyntax)
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	if err != nil {
+		return fail(ErrSyntax)
+	}
+
+	value *= multiplier
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// Issuers publish dates as month/day/year unless a layout is given
+var DateLayouts = []string{"01/02/2006", "1/2/2006"}
+
+// Date parses a date with the first matching layout, DateLayouts when none
+// are given
+func Date(fund, field, raw string, layouts ...string) (time.Time, error) {
+	s := normalizeSpace(raw)
+	if s == "" {
+		return time.Time{}, &Error{Fund: fund, Field: field, Raw: raw, Err: ErrEmpty}
+	}
+
+	if len(layouts) == 0 {
+		layouts = DateLayouts
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, &Error{Fund: fund, Field: field, Raw: raw,
+		Err: fmt.Errorf("%w, want %s", ErrDate, strings.Join(layouts, " or "))}
+}
+
+// normalizeSpace turns every unicode space, such as a non-breaking or thin
+// space, into a single plain one, drops zero width characters and trims the
+// ends
+func normalizeSpace(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.Is(unicode.Zs, r) {
+			return ' '
+		}
+		if r == '\u200b' || r == '\ufeff' {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// trimUnits removes units and currency symbols from both ends
+func trimUnits(s string) string {
+	for trimmed := true; trimmed; {
+		trimmed = false
+		lower := strings.ToLower(s)
+		for _, unit := range units {
+			if strings.HasPrefix(lower, unit) {
+				s, trimmed = strings.TrimSpace(s[len(unit):]), true
+				break
+			}
+			if strings.HasSuffix(lower, unit) {
+				s, trimmed = strings.TrimSpace(s[:len(s)-len(unit)]), true
+				break
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package parse
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    float64
+		wantErr error
+	}{
+		{raw: "1234.5", want: 1234.5},
+		{raw: " 1,234,567.891 ", want: 1234567.891},
+		{raw: "$12.34", want: 12.34},
+		{raw: "(1,000)", want: -1000},
+		{raw: "-2.5 BTC", want: -2.5},
+		{raw: "\u22122.5", want: -2.5},
+		{raw: "45,123.4 bitcoin", want: 45123.4},
+		{raw: "1\u00a0234\u202f567", want: 1234567},
+		{raw: "\u200b250,000\u00a0", want: 250000},
+		{raw: "$1.2B", want: 1.2e9},
+		{raw: "3.4 M", want: 3.4e6},
+		{raw: ".5", want: 0.5},
+		{raw: "", wantErr: ErrEmpty},
+		{raw: "\u00a0", wantErr: ErrEmpty},
+		{raw: "N/A", wantErr: ErrSyntax},
+		{raw: "--", wantErr: ErrSyntax},
+		{raw: "1,23,456", wantErr: ErrSyntax},
+		{raw: "1.2.3", wantErr: ErrSyntax},
+		{raw: "12 ounces", wantErr: ErrSyntax},
+		{raw: "(-5)", wantErr: ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := Number("IBIT", "holdings", tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Number(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Number(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	_, err := Number("ARKB", "holdings", "n/a")
+
+	var parseErr *Error
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("error %T is not an *Error", err)
+	}
+	if parseErr.Fund != "ARKB" || parseErr.Field != "holdings" || parseErr.Raw != "n/a" {
+		t.Errorf("error fields = %+v", parseErr)
+	}
+	if want := `ARKB holdings: cannot parse "n/a": not a number`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestDate(t *testing.T) {
+	want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, raw := range []string{"03/01/2024", "3/1/2024", " 03/01/2024\u00a0"} {
+		if got, err := Date("GBTC", "date", raw); err != nil || !got.Equal(want) {
+			t.Errorf("Date(%q) = %v, %v, want %v", raw, got, err, want)
+		}
+	}
+	if got, err := Date("HODL", "date", "2024-03-01", "2006-01-02"); err != nil || !got.Equal(want) {
+		t.Errorf("Date() with layout = %v, %v", got, err)
+	}
+
+	if _, err := Date("GBTC", "date", "2024-03-01"); !errors.Is(err, ErrDate) {
+		t.Errorf("Date() error = %v, want ErrDate", err)
+	}
+	if _, err := Date("GBTC", "date", ""); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Date() error = %v, want ErrEmpty", err)
+	}
+}
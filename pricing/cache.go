@@ -0,0 +1,181 @@
+package pricing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/cmebrrny"
+)
+
+// NextBRRNY returns the first BRRNY fixing due after t. The rate is
+// published at 4pm New York time every day, weekends included.
+func NextBRRNY(t time.Time) time.Time {
+	next, err := cmebrrny.Benchmarks[cmebrrny.BRRNY].NextPublication(t)
+	if err != nil {
+		// Fall back to a fixed offset when the zone database is missing
+		next = t.Add(24 * time.Hour)
+	}
+	return next
+}
+
+// CacheState is a snapshot of the cache for the API
+type CacheState struct {
+	Provider  string    `json:"provider"`
+	Latest    *Price    `json:"latest,omitempty"`
+	Fetched   time.Time `json:"fetched"`
+	NextDue   time.Time `json:"nextDue"`
+	Attempts  int       `json:"attempts"`
+	NextRetry time.Time `json:"nextRetry"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// Cache holds the latest prices and refreshes them when a new fixing is due.
+// Until the new fixing appears it retries with exponential backoff.
+type Cache struct {
+	Provider Provider
+	// Schedule returns the next fixing due after the given fixing time
+	Schedule func(time.Time) time.Time
+	// History records every refresh when set
+	History *History
+
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	mu        sync.Mutex
+	prices    []Price
+	fetched   time.Time
+	attempts  int
+	nextRetry time.Time
+	lastErr   string
+}
+
+func NewCache(provider Provider, history *History) *Cache {
+	return &Cache{
+		Provider:   provider,
+		Schedule:   NextBRRNY,
+		History:    history,
+		Backoff:    time.Minute,
+		MaxBackoff: 30 * time.Minute,
+	}
+}
+
+// Prices returns the cached prices, newest first. The provider is only
+// called here when nothing has been cached yet.
+func (c *Cache) Prices(ctx context.Context) []Price {
+	c.mu.Lock()
+	empty := len(c.prices) == 0
+	c.mu.Unlock()
+
+	if empty {
+		c.Refresh(ctx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.prices
+}
+
+// nextDue is when the fixing after the cached one should be published. Callers hold c.mu.
+func (c *Cache) nextDue() time.Time {
+	if len(c.prices) == 0 {
+		return time.Time{}
+	}
+	return c.Schedule(c.prices[0].Date)
+}
+
+// Refresh fetches prices and reports whether a newer fixing was found
+func (c *Cache) Refresh(ctx context.Context) bool {
+	prices, err := c.Provider.Prices(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if err == nil && len(prices) > 0 && (len(c.prices) == 0 || prices[0].Date.After(c.prices[0].Date)) {
+		c.prices = prices
+		c.fetched = now
+		c.attempts = 0
+		c.nextRetry = time.Time{}
+		c.lastErr = ""
+
+		log.Printf("Set prices from %s: %+v", prices[0].Provider, prices[0])
+
+		if c.History != nil {
+			if err := c.History.Record(prices); err != nil {
+				log.Println("Price history save error:", err)
+			}
+		}
+
+		return true
+	}
+
+	// Either the fetch failed or the new fixing is not out yet
+	c.attempts++
+	wait := c.Backoff << (c.attempts - 1)
+	if wait > c.MaxBackoff || wait <= 0 {
+		wait = c.MaxBackoff
+	}
+	c.nextRetry = now.Add(wait)
+	if err != nil {
+		c.lastErr = err.Error()
+		log.Printf("Get prices error, retry in %s: %v", wait, err)
+	} else {
+		c.lastErr = ""
+		log.Printf("New fixing not published yet, retry in %s", wait)
+	}
+
+	return false
+}
+
+// wait returns how long until the next refresh should run
+func (c *Cache) wait(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := c.nextDue()
+	if c.nextRetry.After(next) {
+		next = c.nextRetry
+	}
+	return next.Sub(now)
+}
+
+// Run refreshes the cache whenever a new fixing is due until ctx is done
+func (c *Cache) Run(ctx context.Context) {
+	for {
+		if wait := c.wait(time.Now()); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		c.Refresh(ctx)
+	}
+}
+
+// State returns a snapshot of the cache
+func (c *Cache) State() CacheState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := CacheState{
+		Provider:  c.Provider.Name(),
+		Fetched:   c.fetched,
+		NextDue:   c.nextDue(),
+		Attempts:  c.attempts,
+		NextRetry: c.nextRetry,
+		LastError: c.lastErr,
+	}
+	if len(c.prices) > 0 {
+		latest := c.prices[0]
+		state.Latest = &latest
+	}
+
+	return state
+}
@@ -0,0 +1,33 @@
+package pricing
+
+import (
+	"context"
+
+	"github.com/jyap808/btcEtfScrape/cmebrrny"
+)
+
+const cmeName = "CMEBRRNY"
+
+// CME is the CME CF Bitcoin Reference Rate New York Variant
+type CME struct{}
+
+func (CME) Name() string {
+	return cmeName
+}
+
+func (c CME) Prices(ctx context.Context) ([]Price, error) {
+	rr, err := cmebrrny.GetBRRYNY()
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []Price
+	for _, rate := range rr {
+		if rate.Value == 0 {
+			continue
+		}
+		prices = append(prices, Price{Value: rate.Value, Date: rate.Date, Provider: c.Name()})
+	}
+
+	return prices, nil
+}
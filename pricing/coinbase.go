@@ -0,0 +1,73 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const coinbaseCandlesURL = "https://api.exchange.coinbase.com/products/BTC-USD/candles?granularity=86400"
+
+// Coinbase uses the BTC-USD daily close. Days close at 00:00 UTC, not at the 4pm ET fixing.
+type Coinbase struct {
+	// URL overrides the candles endpoint
+	URL    string
+	Client *http.Client
+}
+
+func (Coinbase) Name() string {
+	return "Coinbase"
+}
+
+func (c Coinbase) Prices(ctx context.Context) ([]Price, error) {
+	url := c.URL
+	if url == "" {
+		url = coinbaseCandlesURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "btcEtfScrape")
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	// Each candle is [time, low, high, open, close, volume], newest first
+	var candles [][6]float64
+	if err := json.Unmarshal(body, &candles); err != nil {
+		return nil, err
+	}
+
+	var prices []Price
+	for _, candle := range candles {
+		// The close belongs to the end of the candle's day
+		date := time.Unix(int64(candle[0]), 0).UTC().AddDate(0, 0, 1)
+		if date.After(time.Now()) {
+			// Today's candle is still open
+			continue
+		}
+		prices = append(prices, Price{Value: candle[4], Date: date, Provider: c.Name()})
+	}
+
+	return prices, nil
+}
@@ -0,0 +1,71 @@
+package pricing
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV reads prices from a local file of date,price rows such as
+// "2024-02-16,51840.37". Dates may include a time as "2024-02-16 21:00:00".
+type CSV struct {
+	Path string
+}
+
+func (CSV) Name() string {
+	return "CSV"
+}
+
+func (c CSV) Prices(ctx context.Context) ([]Price, error) {
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []Price
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s line %d: expected date,price", c.Path, i+1)
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			// Allow a header row
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%s line %d: %w", c.Path, i+1, err)
+		}
+
+		date, err := parseCSVDate(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", c.Path, i+1, err)
+		}
+
+		prices = append(prices, Price{Value: value, Date: date, Provider: c.Name()})
+	}
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Date.After(prices[j].Date)
+	})
+
+	return prices, nil
+}
+
+func parseCSVDate(raw string) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02 15:04:05", raw, time.UTC); err == nil {
+		return date, nil
+	}
+	return time.ParseInLocation("2006-01-02", raw, time.UTC)
+}
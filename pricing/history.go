@@ -0,0 +1,261 @@
+package pricing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var newYork = loadNewYork()
+
+func loadNewYork() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// FixingDay is the New York calendar day a price was fixed on
+func FixingDay(t time.Time) string {
+	return t.In(newYork).Format("2006-01-02")
+}
+
+// TradeDay is the calendar day of a holdings date. Issuer dates carry no
+// time of day so they are read in their own location.
+func TradeDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// History keeps every price seen, one per New York fixing day, in a JSON file.
+// A CME fixing is never replaced by a fallback provider's price for its day.
+type History struct {
+	path   string
+	mu     sync.Mutex
+	prices map[string]Price
+}
+
+// OpenHistory loads the history at path, starting empty if it does not exist.
+// An empty path keeps the history in memory only.
+func OpenHistory(path string) (*History, error) {
+	h := &History{path: path, prices: map[string]Price{}}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []Price
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		h.prices[FixingDay(price.Date)] = price
+	}
+
+	return h, nil
+}
+
+// Record adds prices to the history and saves it when anything changed
+func (h *History) Record(prices []Price) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changed := false
+	for _, price := range prices {
+		day := FixingDay(price.Date)
+		if price.Value == 0 || price.Date.IsZero() {
+			continue
+		}
+		current, ok := h.prices[day]
+		if ok && current.Provider == cmeName && price.Provider != cmeName {
+			continue
+		}
+		if !ok || current.Value != price.Value || current.Provider != price.Provider || !current.Date.Equal(price.Date) {
+			h.prices[day] = price
+			changed = true
+		}
+	}
+	if !changed || h.path == "" {
+		return nil
+	}
+
+	return h.save()
+}
+
+// On returns the price fixed on the trade day of date
+func (h *History) On(date time.Time) (Price, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	price, ok := h.prices[TradeDay(date)]
+	return price, ok
+}
+
+// Before returns the latest price fixed before the trade day of date
+func (h *History) Before(date time.Time) (Price, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	day := TradeDay(date)
+	var best Price
+	found := false
+	for fixing, price := range h.prices {
+		if fixing < day && (!found || price.Date.After(best.Date)) {
+			best = price
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// Range returns the prices fixed from the trade day of from to the trade day
+// of to, oldest first
+func (h *History) Range(from, to time.Time) []Price {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	first, last := TradeDay(from), TradeDay(to)
+	var prices []Price
+	for day, price := range h.prices {
+		if day >= first && day <= last {
+			prices = append(prices, price)
+		}
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Date.Before(prices[j].Date)
+	})
+
+	return prices
+}
+
+// Len is the number of stored prices
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.prices)
+}
+
+// ImportCSV adds a provider's prices from date,value rows. Dates are
+// "2006-01-02 15:04:05" in UTC like the CME response, or a bare "2006-01-02"
+// taken as the 4pm New York fixing. A header row is skipped.
+func (h *History) ImportCSV(r io.Reader, provider string) (int, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	var prices []Price
+	for i, record := range records {
+		if len(record) < 2 {
+			return 0, fmt.Errorf("line %d: expected date,value", i+1)
+		}
+
+		value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(record[1]), ",", ""), 64)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return 0, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		date, err := parseFixingDate(strings.TrimSpace(record[0]))
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		prices = append(prices, Price{Value: value, Date: date, Provider: provider})
+	}
+
+	return len(prices), h.Record(prices)
+}
+
+func parseFixingDate(raw string) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02 15:04:05", raw, time.UTC); err == nil {
+		return date, nil
+	}
+
+	day, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), 16, 0, 0, 0, newYork).UTC(), nil
+}
+
+func (h *History) save() error {
+	prices := make([]Price, 0, len(h.prices))
+	for _, price := range h.prices {
+		prices = append(prices, price)
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Date.Before(prices[j].Date)
+	})
+
+	data, err := json.MarshalIndent(prices, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, h.path)
+}
+
+// Archive is a store of past prices that can be searched by trade date
+type Archive interface {
+	On(date time.Time) (Price, bool)
+	Before(date time.Time) (Price, bool)
+}
+
+// Lookup finds the price for a holdings trade date. The recent window is
+// checked first, then each archive in order. When no price was fixed on that
+// day the latest earlier price is returned with exact set to false.
+func Lookup(window []Price, tradeDate time.Time, archives ...Archive) (price Price, exact bool, found bool) {
+	day := TradeDay(tradeDate)
+	for _, p := range window {
+		if FixingDay(p.Date) == day {
+			return p, true, true
+		}
+	}
+
+	for _, archive := range archives {
+		if p, ok := archive.On(tradeDate); ok {
+			return p, true, true
+		}
+	}
+
+	// Nearest earlier price from the window or archives
+	for _, p := range window {
+		if FixingDay(p.Date) < day && (!found || p.Date.After(price.Date)) {
+			price = p
+			found = true
+		}
+	}
+	for _, archive := range archives {
+		if p, ok := archive.Before(tradeDate); ok && (!found || p.Date.After(price.Date)) {
+			price = p
+			found = true
+		}
+	}
+
+	return price, false, found
+}
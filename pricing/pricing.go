@@ -0,0 +1,60 @@
+/*
+Package pricing supplies the daily bitcoin price used to value flows.
+
+CME CF BRRNY is the primary source. Other providers can be chained behind it
+so a failing source does not leave flows unpriced.
+*/
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Price is one daily price and where it came from
+type Price struct {
+	Value    float64
+	Date     time.Time
+	Provider string
+}
+
+type Provider interface {
+	Name() string
+	// Prices returns the most recent daily prices, newest first
+	Prices(ctx context.Context) ([]Price, error)
+}
+
+// Fallback tries each provider in order and returns the first that has prices
+type Fallback struct {
+	Providers []Provider
+}
+
+func (f *Fallback) Name() string {
+	names := make([]string, len(f.Providers))
+	for i, p := range f.Providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *Fallback) Prices(ctx context.Context) ([]Price, error) {
+	var errs []error
+	for _, p := range f.Providers {
+		prices, err := p.Prices(ctx)
+		if err == nil && len(prices) == 0 {
+			err = fmt.Errorf("no prices")
+		}
+		if err != nil {
+			log.Printf("Price provider %s error: %v", p.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			continue
+		}
+		return prices, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
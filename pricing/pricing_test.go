@@ -0,0 +1,258 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticProvider struct {
+	name   string
+	prices []Price
+	err    error
+}
+
+func (s staticProvider) Name() string { return s.name }
+
+func (s staticProvider) Prices(ctx context.Context) ([]Price, error) { return s.prices, s.err }
+
+func TestFallback_Prices(t *testing.T) {
+	f := &Fallback{Providers: []Provider{
+		staticProvider{name: "down", err: errors.New("blocked")},
+		staticProvider{name: "empty"},
+		staticProvider{name: "up", prices: []Price{{Value: 51840.37, Provider: "up"}}},
+	}}
+
+	prices, err := f.Prices(context.Background())
+	if err != nil {
+		t.Fatalf("Prices() error = %v", err)
+	}
+	if prices[0].Provider != "up" {
+		t.Errorf("Prices() provider = %q, want up", prices[0].Provider)
+	}
+
+	f.Providers = f.Providers[:2]
+	if _, err := f.Prices(context.Background()); err == nil {
+		t.Error("Prices() want error when every provider fails")
+	}
+}
+
+func TestCSV_Prices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	data := "date,price\n2024-02-15,51600.12\n2024-02-16 21:00:00,51840.37\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prices, err := CSV{Path: path}.Prices(context.Background())
+	if err != nil {
+		t.Fatalf("Prices() error = %v", err)
+	}
+
+	want := []Price{
+		{Value: 51840.37, Date: time.Date(2024, 2, 16, 21, 0, 0, 0, time.UTC), Provider: "CSV"},
+		{Value: 51600.12, Date: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), Provider: "CSV"},
+	}
+	if len(prices) != len(want) {
+		t.Fatalf("Prices() got %d prices, want %d", len(prices), len(want))
+	}
+	for i := range want {
+		if prices[i] != want[i] {
+			t.Errorf("Prices()[%d] got = %+v, want %+v", i, prices[i], want[i])
+		}
+	}
+}
+
+func TestCoinbase_Prices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 2024-02-16 and 2024-02-15 candles
+		w.Write([]byte(`[[1708041600,51000,52800,51900,52100.5,9000],[1707955200,50500,52000,51600,51900,8000]]`))
+	}))
+	defer server.Close()
+
+	prices, err := Coinbase{URL: server.URL}.Prices(context.Background())
+	if err != nil {
+		t.Fatalf("Prices() error = %v", err)
+	}
+	if len(prices) != 2 || prices[0].Value != 52100.5 || !prices[0].Date.Equal(time.Date(2024, 2, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Prices() got = %+v", prices)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	fixing := func(d int) time.Time {
+		return time.Date(2024, 2, d, 21, 0, 0, 0, time.UTC)
+	}
+	trade := func(d int) time.Time {
+		return time.Date(2024, 2, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	window := []Price{
+		{Value: 52000, Date: fixing(16), Provider: "CMEBRRNY"},
+		{Value: 51600, Date: fixing(15), Provider: "CMEBRRNY"},
+	}
+	history, err := OpenHistory(filepath.Join(t.TempDir(), "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.Record([]Price{{Value: 48000, Date: fixing(9), Provider: "CMEBRRNY"}})
+
+	tests := []struct {
+		name      string
+		tradeDate time.Time
+		wantValue float64
+		wantExact bool
+		wantFound bool
+	}{
+		{name: "latest", tradeDate: trade(16), wantValue: 52000, wantExact: true, wantFound: true},
+		{name: "previous day", tradeDate: trade(15), wantValue: 51600, wantExact: true, wantFound: true},
+		{name: "history", tradeDate: trade(9), wantValue: 48000, wantExact: true, wantFound: true},
+		{name: "after the window", tradeDate: trade(17), wantValue: 52000, wantExact: false, wantFound: true},
+		{name: "gap uses history", tradeDate: trade(12), wantValue: 48000, wantExact: false, wantFound: true},
+		{name: "before everything", tradeDate: trade(1), wantFound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, exact, found := Lookup(window, tt.tradeDate, history)
+			if found != tt.wantFound || exact != tt.wantExact || (found && price.Value != tt.wantValue) {
+				t.Errorf("Lookup() = %v, %v, %v, want %v, %v, %v", price.Value, exact, found, tt.wantValue, tt.wantExact, tt.wantFound)
+			}
+		})
+	}
+
+	// History survives a reopen
+	reopened, err := OpenHistory(history.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price, ok := reopened.On(trade(9)); !ok || price.Value != 48000 {
+		t.Errorf("On() after reopen = %+v, %v", price, ok)
+	}
+}
+
+type sequenceProvider struct {
+	calls  int
+	prices [][]Price
+}
+
+func (s *sequenceProvider) Name() string { return "sequence" }
+
+func (s *sequenceProvider) Prices(ctx context.Context) ([]Price, error) {
+	i := s.calls
+	if i >= len(s.prices) {
+		i = len(s.prices) - 1
+	}
+	s.calls++
+	return s.prices[i], nil
+}
+
+func TestHistory_ImportCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csvData := "date,value\n2024-02-14,51600.12\n2024-02-15 21:00:00,\"51,700.50\"\n2024-02-16 21:00:00,51840.37\n"
+	n, err := h.ImportCSV(strings.NewReader(csvData), "CMEBRRNY")
+	if err != nil || n != 3 {
+		t.Fatalf("ImportCSV() = %d, %v, want 3 rows", n, err)
+	}
+	// A fallback price does not replace a CME fixing for the same day
+	h.Record([]Price{{Value: 50000, Date: time.Date(2024, 2, 16, 0, 0, 0, 0, time.UTC), Provider: "Coinbase"}})
+
+	// Reopen to check the history was saved
+	h, err = OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		date      time.Time
+		wantValue float64
+		wantDate  time.Time
+	}{
+		{name: "bare date is the 4pm New York fixing", date: time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC), wantValue: 51600.12, wantDate: time.Date(2024, 2, 14, 21, 0, 0, 0, time.UTC)},
+		{name: "thousands separator", date: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), wantValue: 51700.50, wantDate: time.Date(2024, 2, 15, 21, 0, 0, 0, time.UTC)},
+		{name: "trade date in New York", date: time.Date(2024, 2, 16, 0, 0, 0, 0, newYork), wantValue: 51840.37, wantDate: time.Date(2024, 2, 16, 21, 0, 0, 0, time.UTC)},
+		{name: "not stored", date: time.Date(2024, 2, 17, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := h.On(tt.date)
+			if ok != (tt.wantValue != 0) {
+				t.Fatalf("On() found = %v, want %v", ok, tt.wantValue != 0)
+			}
+			if ok && (got.Value != tt.wantValue || !got.Date.Equal(tt.wantDate) || got.Provider != "CMEBRRNY") {
+				t.Errorf("On() = %+v, want %v at %s", got, tt.wantValue, tt.wantDate)
+			}
+		})
+	}
+
+	prices := h.Range(time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC))
+	if len(prices) != 2 || prices[0].Value != 51700.50 || prices[1].Value != 51840.37 {
+		t.Errorf("Range() = %+v", prices)
+	}
+}
+
+func TestNextBRRNY(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{name: "friday fixing", t: time.Date(2024, 2, 16, 21, 0, 0, 0, time.UTC), want: time.Date(2024, 2, 17, 16, 0, 0, 0, newYork)},
+		{name: "saturday morning", t: time.Date(2024, 2, 17, 9, 0, 0, 0, newYork), want: time.Date(2024, 2, 17, 16, 0, 0, 0, newYork)},
+		{name: "sunday fixing", t: time.Date(2024, 2, 18, 16, 0, 0, 0, newYork), want: time.Date(2024, 2, 19, 16, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextBRRNY(tt.t); !got.Equal(tt.want) {
+				t.Errorf("NextBRRNY() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCache_Refresh(t *testing.T) {
+	thursday := Price{Value: 51600, Date: time.Date(2024, 2, 15, 21, 0, 0, 0, time.UTC)}
+	friday := Price{Value: 52000, Date: time.Date(2024, 2, 16, 21, 0, 0, 0, time.UTC)}
+	provider := &sequenceProvider{prices: [][]Price{{thursday}, {thursday}, {friday, thursday}}}
+
+	c := NewCache(provider, nil)
+	if prices := c.Prices(context.Background()); prices[0] != thursday {
+		t.Fatalf("Prices() got = %+v", prices)
+	}
+
+	// Friday's fixing is due but not out yet, so back off
+	if c.Refresh(context.Background()) {
+		t.Error("Refresh() reported a new fixing")
+	}
+	state := c.State()
+	if state.Attempts != 1 || state.NextRetry.IsZero() {
+		t.Errorf("State() after retry = %+v", state)
+	}
+	if wait := c.wait(time.Now()); wait <= 0 || wait > time.Minute {
+		t.Errorf("wait() = %s, want the backoff", wait)
+	}
+
+	if !c.Refresh(context.Background()) {
+		t.Error("Refresh() missed the new fixing")
+	}
+	state = c.State()
+	if state.Attempts != 0 || state.Latest.Value != friday.Value {
+		t.Errorf("State() after new fixing = %+v", state)
+	}
+	if provider.calls != 3 {
+		t.Errorf("provider called %d times, want 3", provider.calls)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/calendar"
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+const (
+	statusQuarantined = "quarantined"
+	statusNegative    = "rejected negative holdings"
+)
+
+// quarantineEntry is a scraped result held back until it is confirmed
+type quarantineEntry struct {
+	Ticker string       `json:"ticker"`
+	Result types.Result `json:"result"`
+	Reason string       `json:"reason"`
+	Since  time.Time    `json:"since"`
+	// Confirmed by an operator, accepted on the fund's next poll
+	Confirmed bool   `json:"confirmed"`
+	ConfirmBy string `json:"confirmedBy,omitempty"`
+}
+
+// Guarded by resultsMu
+var (
+	quarantined = map[string]quarantineEntry{}
+	// Results an operator rejected, held again whenever they are scraped
+	rejected = map[string]types.Result{}
+)
+
+// rejectedReason is the quarantine reason of a result an operator rejected
+const rejectedReason = "rejected by an operator"
+
+// implausible returns why a scraped result needs confirmation before it is
+// accepted, or an empty string when it looks fine
+func implausible(c *config.Config, ticker string, current, result types.Result) string {
+	// An unchanged result is never posted
+	if result.TotalAsset == current.TotalAsset {
+		return ""
+	}
+	if maxPct := c.FundMaxChangePct(ticker); maxPct > 0 && current.TotalAsset > 0 {
+		pct := math.Abs(result.TotalAsset-current.TotalAsset) / current.TotalAsset * 100
+		if pct > maxPct {
+			return fmt.Sprintf("holdings change of %.1f%% exceeds %.1f%%", pct, maxPct)
+		}
+	}
+	if r := result.Reconciliation; r != nil && r.Status == types.Mismatched {
+		return mismatchReason(*r, result, c.FundReconcileTolerancePct(ticker))
+	}
+	if !c.AllowNonTradingDays && !result.Date.IsZero() && !calendar.IsTradingDay(result.Date) {
+		return fmt.Sprintf("dated %s %s, not a trading day", result.Date.Weekday(), result.Date.Format("2006-01-02"))
+	}
+	return ""
+}
+
+// sameResult reports whether two scrapes returned the same holdings
+func sameResult(a, b types.Result) bool {
+	return a.TotalAsset == b.TotalAsset && a.Date.Equal(b.Date)
+}
+
+// guardScrape decides whether a scraped result can be accepted. A suspicious
+// result is quarantined and accepted once a second consecutive scrape returns
+// the same value. A result an operator rejected stays quarantined until the
+// fund returns a different one.
+func guardScrape(ticker string, current, result types.Result) bool {
+	reason := implausible(conf(), ticker, current, result)
+
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	held, isHeld := quarantined[ticker]
+	if last, ok := rejected[ticker]; ok {
+		if sameResult(last, result) {
+			if !isHeld {
+				log.Printf("%s result rejected by an operator scraped again: %+v", ticker, result)
+				quarantined[ticker] = quarantineEntry{Ticker: ticker, Result: result, Reason: rejectedReason, Since: time.Now()}
+			}
+			status := tickerStatus[ticker]
+			status.Status = statusQuarantined
+			tickerStatus[ticker] = status
+			return false
+		}
+		delete(rejected, ticker)
+	}
+
+	if reason == "" {
+		if isHeld {
+			log.Printf("%s quarantine cleared by a plausible scrape: %+v", ticker, result)
+			delete(quarantined, ticker)
+		}
+		return true
+	}
+
+	if isHeld && sameResult(held.Result, result) {
+		log.Printf("%s quarantined result confirmed by a second scrape: %+v", ticker, result)
+		delete(quarantined, ticker)
+		return true
+	}
+
+	log.Printf("%s result quarantined, %s: %+v", ticker, reason, result)
+	quarantined[ticker] = quarantineEntry{Ticker: ticker, Result: result, Reason: reason, Since: time.Now()}
+	status := tickerStatus[ticker]
+	status.Status = statusQuarantined
+	tickerStatus[ticker] = status
+	return false
+}
+
+// takeConfirmed returns and clears a quarantined result an operator confirmed
+func takeConfirmed(ticker string) (types.Result, bool) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	held, ok := quarantined[ticker]
+	if !ok || !held.Confirmed {
+		return types.Result{}, false
+	}
+	delete(quarantined, ticker)
+
+	return held.Result, true
+}
+
+// handleQuarantine lists the quarantined results
+func handleQuarantine(w http.ResponseWriter, r *http.Request) {
+	resultsMu.Lock()
+	entries := make([]quarantineEntry, 0, len(quarantined))
+	for _, entry := range quarantined {
+		entries = append(entries, entry)
+	}
+	resultsMu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Ticker < entries[j].Ticker
+	})
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleQuarantineDecision confirms or rejects a quarantined result. A
+// confirmed result is posted as a normal flow on the fund's next poll, while a
+// rejected one is quarantined again if the fund keeps returning it.
+func handleQuarantineDecision(w http.ResponseWriter, r *http.Request, confirm bool) {
+	var request struct {
+		Ticker string
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	resultsMu.Lock()
+	held, ok := quarantined[request.Ticker]
+	if ok {
+		if confirm {
+			held.Confirmed = true
+			held.ConfirmBy = actorFrom(r)
+			quarantined[request.Ticker] = held
+			delete(rejected, request.Ticker)
+		} else {
+			delete(quarantined, request.Ticker)
+			rejected[request.Ticker] = held.Result
+		}
+	}
+	resultsMu.Unlock()
+
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no quarantined result for %q", request.Ticker))
+		return
+	}
+
+	action := "quarantine reject"
+	if confirm {
+		action = "quarantine confirm"
+		audit(r, action, request.Ticker, nil, held.Result)
+	} else {
+		audit(r, action, request.Ticker, held.Result, nil)
+	}
+	log.Printf("%s %s by %s: %+v", action, request.Ticker, actorFrom(r), held.Result)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": action + " successful",
+		"ticker": request.Ticker,
+		"result": held.Result,
+	})
+}
+
+func handleQuarantineConfirm(w http.ResponseWriter, r *http.Request) {
+	handleQuarantineDecision(w, r, true)
+}
+
+func handleQuarantineReject(w http.ResponseWriter, r *http.Request) {
+	handleQuarantineDecision(w, r, false)
+}
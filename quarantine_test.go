@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestImplausible(t *testing.T) {
+	c := config.Default()
+	loose := 80.0
+	fund := c.Funds["FBTC"]
+	fund.MaxChangePct = &loose
+	c.Funds["FBTC"] = fund
+
+	friday := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	saturday := friday.AddDate(0, 0, 1)
+	current := types.Result{TotalAsset: 1000, Date: friday.AddDate(0, 0, -1)}
+
+	tests := []struct {
+		name   string
+		ticker string
+		result types.Result
+		want   string
+	}{
+		{name: "normal flow", ticker: "IBIT", result: types.Result{TotalAsset: 1100, Date: friday}, want: ""},
+		{name: "ten times", ticker: "IBIT", result: types.Result{TotalAsset: 10000, Date: friday}, want: "holdings change of 900.0% exceeds 20.0%"},
+		{name: "fund threshold", ticker: "FBTC", result: types.Result{TotalAsset: 1500, Date: friday}, want: ""},
+		{name: "weekend", ticker: "IBIT", result: types.Result{TotalAsset: 1010, Date: saturday}, want: "dated Saturday 2024-03-02, not a trading day"},
+		{name: "holiday", ticker: "IBIT", result: types.Result{TotalAsset: 1010, Date: time.Date(2024, 2, 19, 0, 0, 0, 0, time.UTC)}, want: "dated Monday 2024-02-19, not a trading day"},
+		{name: "undated", ticker: "IBIT", result: types.Result{TotalAsset: 1010}, want: ""},
+		{name: "unchanged", ticker: "IBIT", result: types.Result{TotalAsset: 1000, Date: saturday}, want: ""},
+		{name: "mismatched", ticker: "IBIT", result: types.Result{TotalAsset: 1010, Date: friday, SharesOutstanding: 1000, BitcoinPerShare: 1,
+			Reconciliation: &types.Reconciliation{Status: types.Mismatched, Implied: 1000, DiffPct: 1}},
+			want: "holdings of 1010.00 differ by 1.00% from the 1000.00 implied by 1000 shares at 1 bitcoin each, more than 0.50%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := implausible(c, tt.ticker, current, tt.result); got != tt.want {
+				t.Errorf("implausible() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	c.AllowNonTradingDays = true
+	if got := implausible(c, "IBIT", current, types.Result{TotalAsset: 1010, Date: saturday}); got != "" {
+		t.Errorf("implausible() with AllowNonTradingDays = %q", got)
+	}
+}
+
+func TestGuardScrape(t *testing.T) {
+	defer delete(quarantined, "ARKB")
+
+	current := types.Result{TotalAsset: 1000}
+	bad := types.Result{TotalAsset: 100000}
+
+	if guardScrape("ARKB", current, bad) {
+		t.Fatal("first implausible scrape accepted")
+	}
+	if _, ok := quarantined["ARKB"]; !ok {
+		t.Fatal("implausible scrape not quarantined")
+	}
+	if guardScrape("ARKB", current, types.Result{TotalAsset: 90000}) {
+		t.Fatal("different implausible scrape accepted")
+	}
+	if quarantined["ARKB"].Result.TotalAsset != 90000 {
+		t.Errorf("quarantine not replaced: %+v", quarantined["ARKB"])
+	}
+	if !guardScrape("ARKB", current, types.Result{TotalAsset: 90000}) {
+		t.Fatal("second identical scrape not accepted")
+	}
+	if _, ok := quarantined["ARKB"]; ok {
+		t.Error("quarantine kept after confirmation")
+	}
+
+	guardScrape("ARKB", current, bad)
+	if !guardScrape("ARKB", current, types.Result{TotalAsset: 1010}) {
+		t.Fatal("plausible scrape not accepted")
+	}
+	if _, ok := quarantined["ARKB"]; ok {
+		t.Error("quarantine kept after a plausible scrape")
+	}
+}
+
+func TestHandleQuarantineDecision(t *testing.T) {
+	auditPath = filepath.Join(t.TempDir(), "audit.log")
+	defer func() {
+		delete(quarantined, "ARKB")
+		delete(rejected, "ARKB")
+	}()
+
+	guardScrape("ARKB", types.Result{TotalAsset: 1000}, types.Result{TotalAsset: 100000})
+	if _, ok := takeConfirmed("ARKB"); ok {
+		t.Fatal("unconfirmed result taken")
+	}
+
+	decide := func(action, ticker string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/quarantine/"+action, strings.NewReader(`{"Ticker": "`+ticker+`"}`))
+		if action == "confirm" {
+			handleQuarantineConfirm(w, r)
+		} else {
+			handleQuarantineReject(w, r)
+		}
+		return w.Code
+	}
+
+	if code := decide("confirm", "IBIT"); code != http.StatusNotFound {
+		t.Errorf("confirm without quarantine = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := decide("confirm", "ARKB"); code != http.StatusOK {
+		t.Fatalf("confirm = %d", code)
+	}
+	if result, ok := takeConfirmed("ARKB"); !ok || result.TotalAsset != 100000 {
+		t.Errorf("takeConfirmed() = %+v, %v", result, ok)
+	}
+
+	guardScrape("ARKB", types.Result{TotalAsset: 1000}, types.Result{TotalAsset: 100000})
+	if code := decide("reject", "ARKB"); code != http.StatusOK {
+		t.Fatalf("reject = %d", code)
+	}
+	if _, ok := quarantined["ARKB"]; ok {
+		t.Error("rejected result still quarantined")
+	}
+
+	// The rejected value is never confirmed by scraping it again
+	for i := 0; i < 2; i++ {
+		if guardScrape("ARKB", types.Result{TotalAsset: 1000}, types.Result{TotalAsset: 100000}) {
+			t.Fatalf("rejected result accepted on scrape %d", i+1)
+		}
+	}
+	if held := quarantined["ARKB"]; held.Reason != rejectedReason {
+		t.Errorf("rejected result quarantined as %+v", held)
+	}
+
+	if !guardScrape("ARKB", types.Result{TotalAsset: 1000}, types.Result{TotalAsset: 1010}) {
+		t.Fatal("new plausible result not accepted")
+	}
+	if _, ok := rejected["ARKB"]; ok {
+		t.Error("rejected result kept after a different one")
+	}
+}
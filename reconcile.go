@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/calendar"
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+// reconcileRule is the rule name of alerts on holdings that do not match
+// shares outstanding times bitcoin per share
+const reconcileRule = "reconciliation-mismatch"
+
+// reconcile records how a scraped result compares with the holdings implied
+// by its shares outstanding and bitcoin per share, and alerts the ops
+// channels once per as-of date, or per day for undated results, when they do
+// not match. Results are left
+// unchecked when the fund's tolerance is zero.
+func reconcile(ticker string, result types.Result) types.Result {
+	tolerance := conf().FundReconcileTolerancePct(ticker)
+	if tolerance == 0 || result.TotalAsset == 0 {
+		return result
+	}
+
+	reconciliation := result.Reconcile(tolerance)
+	result.Reconciliation = &reconciliation
+	if reconciliation.Status != types.Mismatched {
+		return result
+	}
+
+	reason := mismatchReason(reconciliation, result, tolerance)
+	log.Printf("%s holdings do not reconcile: %s", ticker, reason)
+	day := result.Date
+	if day.IsZero() {
+		day = calendar.Day(time.Now())
+	}
+	if alertOnce(reconcileRule, ticker, day.Format("2006-01-02")) {
+		notifyOps(reconcileRule, message.Event{Ticker: ticker, Description: details()[ticker].Description,
+			Date: result.Date, TotalAsset: result.TotalAsset}, reason)
+	}
+	return result
+}
+
+// mismatchReason describes a mismatched reconciliation
+func mismatchReason(reconciliation types.Reconciliation, result types.Result, tolerance float64) string {
+	return fmt.Sprintf("holdings of %.2f differ by %.2f%% from the %.2f implied by %.0f shares at %g bitcoin each, more than %.2f%%",
+		result.TotalAsset, reconciliation.DiffPct, reconciliation.Implied, result.SharesOutstanding, result.BitcoinPerShare, tolerance)
+}
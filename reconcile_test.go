@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestReconcile(t *testing.T) {
+	renderer, _ = message.NewRenderer("")
+	var output bytes.Buffer
+	previousWriter := dryRunWriter
+	dryRun, dryRunWriter = true, &output
+	defer func() {
+		renderer, dryRun, dryRunWriter = nil, false, previousWriter
+		staleAlertedMu.Lock()
+		delete(staleAlerted, reconcileRule+"/GBTC")
+		delete(staleAlerted, "ops:"+reconcileRule+"/GBTC")
+		staleAlertedMu.Unlock()
+	}()
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		result     types.Result
+		wantStatus string
+	}{
+		{name: "unchecked without holdings", result: types.Result{Date: date}},
+		{name: "without shares", result: types.Result{TotalAsset: 1000, Date: date}, wantStatus: types.Unreconciled},
+		{name: "within tolerance", result: types.Result{TotalAsset: 1002, Date: date, SharesOutstanding: 2000, BitcoinPerShare: 0.5}, wantStatus: types.Reconciled},
+		{name: "mismatched", result: types.Result{TotalAsset: 1100, Date: date, SharesOutstanding: 2000, BitcoinPerShare: 0.5}, wantStatus: types.Mismatched},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reconcile("GBTC", tt.result)
+			status := ""
+			if got.Reconciliation != nil {
+				status = got.Reconciliation.Status
+			}
+			if status != tt.wantStatus {
+				t.Errorf("reconcile() status = %q, want %q", status, tt.wantStatus)
+			}
+		})
+	}
+
+	if !strings.Contains(output.String(), "differ by 10.00% from the 1000.00 implied") {
+		t.Errorf("mismatch not alerted: %s", output.String())
+	}
+
+	output.Reset()
+	reconcile("GBTC", types.Result{TotalAsset: 1100, Date: date, SharesOutstanding: 2000, BitcoinPerShare: 0.5})
+	if output.Len() != 0 {
+		t.Errorf("mismatch alerted twice for the same date: %s", output.String())
+	}
+	// Undated mismatches are alerted once a day rather than once ever
+	staleAlertedMu.Lock()
+	staleAlerted[reconcileRule+"/GBTC"] = "2024-02-29"
+	staleAlertedMu.Unlock()
+	for i := 0; i < 2; i++ {
+		reconcile("GBTC", types.Result{TotalAsset: 1200, SharesOutstanding: 2000, BitcoinPerShare: 0.5})
+	}
+	if got := strings.Count(output.String(), "kind=alert ticker=GBTC"); got != 1 {
+		t.Errorf("sent %d undated mismatch alerts, want 1: %s", got, output.String())
+	}
+}
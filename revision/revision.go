@@ -0,0 +1,182 @@
+/*
+Package revision records every change to a ticker's accepted holdings.
+
+Each revision keeps the result before and after the change, what caused it and
+the notification it produced, so a bad change can be rolled back and corrected.
+*/
+package revision
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+type Cause string
+
+const (
+	Scrape   Cause = "scrape"
+	Override Cause = "override"
+	Update   Cause = "update"
+	Rollback Cause = "rollback"
+)
+
+// Post identifies the notification a revision produced, matching the outbox
+// kind, trade date and version
+type Post struct {
+	Kind      string `json:"kind"`
+	TradeDate string `json:"tradeDate"`
+	Version   string `json:"version,omitempty"`
+}
+
+type Revision struct {
+	ID       int          `json:"id"`
+	Ticker   string       `json:"ticker"`
+	Time     time.Time    `json:"time"`
+	Cause    Cause        `json:"cause"`
+	Actor    string       `json:"actor,omitempty"`
+	Previous types.Result `json:"previous"`
+	Result   types.Result `json:"result"`
+	Post     *Post        `json:"post,omitempty"`
+	// RollbackOf is the revision a rollback returned to
+	RollbackOf int `json:"rollbackOf,omitempty"`
+}
+
+// Store keeps revisions in a JSON file
+type Store struct {
+	path      string
+	mu        sync.Mutex
+	nextID    int
+	revisions map[string][]Revision
+
+	// MaxPerTicker oldest revisions are dropped beyond this
+	MaxPerTicker int
+}
+
+// Open loads the store at path, starting empty if it does not exist.
+// An empty path keeps revisions in memory only.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, nextID: 1, revisions: map[string][]Revision{}, MaxPerTicker: 500}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []Revision
+	if err := json.Unmarshal(data, &revisions); err != nil {
+		return nil, err
+	}
+	for _, rev := range revisions {
+		s.revisions[rev.Ticker] = append(s.revisions[rev.Ticker], rev)
+		if rev.ID >= s.nextID {
+			s.nextID = rev.ID + 1
+		}
+	}
+	for ticker := range s.revisions {
+		sortByID(s.revisions[ticker])
+	}
+
+	return s, nil
+}
+
+// Record assigns the revision an ID and saves it
+func (s *Store) Record(rev Revision) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev.ID = s.nextID
+	s.nextID++
+	if rev.Time.IsZero() {
+		rev.Time = time.Now()
+	}
+
+	revisions := append(s.revisions[rev.Ticker], rev)
+	if s.MaxPerTicker > 0 && len(revisions) > s.MaxPerTicker {
+		revisions = revisions[len(revisions)-s.MaxPerTicker:]
+	}
+	s.revisions[rev.Ticker] = revisions
+
+	return rev, s.save()
+}
+
+// List returns a ticker's revisions, newest first
+func (s *Store) List(ticker string) []Revision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions := s.revisions[ticker]
+	list := make([]Revision, len(revisions))
+	for i, rev := range revisions {
+		list[len(revisions)-1-i] = rev
+	}
+	return list
+}
+
+// Get returns one of a ticker's revisions by ID
+func (s *Store) Get(ticker string, id int) (Revision, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rev := range s.revisions[ticker] {
+		if rev.ID == id {
+			return rev, true
+		}
+	}
+	return Revision{}, false
+}
+
+// Since returns the revisions of a ticker recorded after id, oldest first
+func (s *Store) Since(ticker string, id int) []Revision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var since []Revision
+	for _, rev := range s.revisions[ticker] {
+		if rev.ID > id {
+			since = append(since, rev)
+		}
+	}
+	return since
+}
+
+func sortByID(revisions []Revision) {
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].ID < revisions[j].ID
+	})
+}
+
+// save writes the store atomically. Callers hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	var revisions []Revision
+	for _, list := range s.revisions {
+		revisions = append(revisions, list...)
+	}
+	sortByID(revisions)
+
+	data, err := json.MarshalIndent(revisions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,70 @@
+package revision
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revisions.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, total := range []float64{100, 150, 90} {
+		rev, err := s.Record(Revision{Ticker: "IBIT", Cause: Scrape, Result: types.Result{TotalAsset: total}, Post: &Post{Kind: "flow", TradeDate: "2024-03-01"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rev.ID != i+1 {
+			t.Errorf("ID = %d, want %d", rev.ID, i+1)
+		}
+	}
+	if _, err := s.Record(Revision{Ticker: "FBTC", Cause: Update, Actor: "alice", Result: types.Result{TotalAsset: 10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen to check the file round trips
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := s.List("IBIT")
+	if len(list) != 3 || list[0].ID != 3 || list[2].ID != 1 {
+		t.Fatalf("List() = %+v, want IDs 3, 2, 1", list)
+	}
+	if rev, ok := s.Get("IBIT", 2); !ok || rev.Result.TotalAsset != 150 || rev.Post == nil {
+		t.Errorf("Get(IBIT, 2) = %+v, %v", rev, ok)
+	}
+	if _, ok := s.Get("IBIT", 4); ok {
+		t.Error("Get(IBIT, 4) found a FBTC revision")
+	}
+	if since := s.Since("IBIT", 1); len(since) != 2 || since[0].ID != 2 {
+		t.Errorf("Since(IBIT, 1) = %+v, want IDs 2, 3", since)
+	}
+
+	rev, err := s.Record(Revision{Ticker: "IBIT", Cause: Rollback, RollbackOf: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.ID != 5 {
+		t.Errorf("ID after reopen = %d, want 5", rev.ID)
+	}
+}
+
+func TestStore_MaxPerTicker(t *testing.T) {
+	s, _ := Open("")
+	s.MaxPerTicker = 2
+	for i := 0; i < 5; i++ {
+		s.Record(Revision{Ticker: "IBIT", Cause: Scrape})
+	}
+
+	list := s.List("IBIT")
+	if len(list) != 2 || list[0].ID != 5 || list[1].ID != 4 {
+		t.Errorf("List() = %+v, want IDs 5, 4", list)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/notifier"
+	"github.com/jyap808/btcEtfScrape/outbox"
+	"github.com/jyap808/btcEtfScrape/revision"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func scrapeCause(override bool) revision.Cause {
+	if override {
+		return revision.Override
+	}
+	return revision.Scrape
+}
+
+// recordRevision stores a change to a ticker's accepted result
+func recordRevision(ticker string, cause revision.Cause, actor string, previous, result types.Result, post *revision.Post) {
+	if revisions == nil {
+		return
+	}
+
+	_, err := revisions.Record(revision.Revision{
+		Ticker:   ticker,
+		Cause:    cause,
+		Actor:    actor,
+		Previous: previous,
+		Result:   result,
+		Post:     post,
+	})
+	if err != nil {
+		log.Printf("Revision %s error: %v", ticker, err)
+	}
+}
+
+// handleRevisions lists a ticker's revisions, newest first
+func handleRevisions(w http.ResponseWriter, r *http.Request) {
+	ticker := r.URL.Query().Get("ticker")
+	if _, ok := details()[ticker]; !ok {
+		writeJSONError(w, http.StatusUnprocessableEntity, fmt.Sprintf("unknown ticker %q", ticker))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revisions.List(ticker))
+}
+
+// handleRollback restores the result of a prior revision. Notifications posted
+// by the revisions it undoes are followed by a correction when Notify is set.
+func handleRollback(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Ticker   string
+		Revision int
+		Notify   bool
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	target, ok := revisions.Get(request.Ticker, request.Revision)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no revision %d for %q", request.Revision, request.Ticker))
+		return
+	}
+	undone := notUndone(revisions.Since(request.Ticker, request.Revision))
+	if len(undone) == 0 {
+		writeJSONError(w, http.StatusUnprocessableEntity, fmt.Sprintf("revision %d is already current", request.Revision))
+		return
+	}
+
+	resultsMu.Lock()
+	before := tickerResults[request.Ticker]
+	tickerResults[request.Ticker] = target.Result
+	// The fund is likely to keep returning the result rolled back, hold it
+	// until it returns a different one
+	if before.TotalAsset != 0 && !sameResult(before, target.Result) {
+		rejected[request.Ticker] = before
+	}
+	resultsMu.Unlock()
+
+	rev, err := revisions.Record(revision.Revision{
+		Ticker:     request.Ticker,
+		Cause:      revision.Rollback,
+		Actor:      actorFrom(r),
+		Previous:   before,
+		Result:     target.Result,
+		RollbackOf: target.ID,
+	})
+	if err != nil {
+		log.Printf("Revision %s error: %v", request.Ticker, err)
+	}
+	audit(r, "rollback", request.Ticker, before, target.Result)
+	log.Printf("Rollback %s to revision %d by %s: %+v", request.Ticker, target.ID, actorFrom(r), target.Result)
+
+	corrections := map[string][]string{}
+	for _, bad := range undone {
+		if bad.Post == nil {
+			continue
+		}
+		dropDailyFlow(bad.Ticker, bad.Post.TradeDate)
+		// Only posts that went out need a correction
+		cancelPost(bad.Ticker, bad.Post)
+		if request.Notify {
+			corrections[bad.Post.TradeDate] = append(corrections[bad.Post.TradeDate], notifyCorrection(bad, target.Result)...)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":      "rollback successful",
+		"revision":    rev,
+		"undone":      undone,
+		"corrections": corrections,
+	})
+}
+
+// notUndone drops the revisions an earlier rollback among them already
+// undid, so they are not cancelled and corrected twice
+func notUndone(since []revision.Revision) []revision.Revision {
+	var kept []revision.Revision
+	for _, rev := range since {
+		covered := false
+		for _, later := range since {
+			if later.Cause == revision.Rollback && later.ID > rev.ID && later.RollbackOf < rev.ID {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, rev)
+		}
+	}
+	return kept
+}
+
+// postEntries returns the outbox entries a revision's post was queued as
+func postEntries(ticker string, post *revision.Post, status outbox.Status) []outbox.Entry {
+	var entries []outbox.Entry
+	for _, entry := range notifications.Entries(status) {
+		if entry.Ticker == ticker && entry.Kind == post.Kind && entry.TradeDate == post.TradeDate && entry.Version == post.Version {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// cancelPost stops the deliveries of a revision's post that have not been sent
+func cancelPost(ticker string, post *revision.Post) {
+	if notifications == nil {
+		return
+	}
+
+	for _, entry := range postEntries(ticker, post, outbox.Pending) {
+		if err := notifications.Cancel(entry.Key); err != nil {
+			log.Printf("Outbox cancel %s error: %v", entry.Key, err)
+			continue
+		}
+		log.Println("Outbox canceled:", entry.Key)
+	}
+}
+
+// postedChannels returns the channels a revision's post was sent on
+func postedChannels(ticker string, post *revision.Post) []string {
+	if notifications == nil {
+		return append([]string{string(message.Discord)}, socialChannels()...)
+	}
+
+	var channels []string
+	for _, entry := range postEntries(ticker, post, outbox.Sent) {
+		channels = append(channels, entry.Channel)
+	}
+	return channels
+}
+
+// notifyCorrection posts a correction on every channel that was sent the bad
+// revision's notification, returning those channels
+func notifyCorrection(bad revision.Revision, restored types.Result) []string {
+	date, _ := time.Parse("2006-01-02", bad.Post.TradeDate)
+	event := message.Event{
+		Ticker:         bad.Ticker,
+		Description:    details()[bad.Ticker].Description,
+		Date:           date,
+		RetractedTotal: bad.Result.TotalAsset,
+		TotalAsset:     restored.TotalAsset,
+	}
+
+	channels := postedChannels(bad.Ticker, bad.Post)
+	for _, channel := range channels {
+		template := message.X
+		if channel == string(message.Discord) {
+			template = message.Discord
+		}
+
+		text, err := templates().Render(template, message.Correction, event)
+		if err != nil {
+			log.Printf("Render %s %s error: %v", template, message.Correction, err)
+			continue
+		}
+		if text == "" {
+			continue
+		}
+
+		msg := notifier.Message{Text: text}
+		if template == message.Discord {
+			msg.Embed = discordEmbed(message.Correction, event)
+		}
+		deliver(channel, message.Correction, event, msg, false)
+	}
+	return channels
+}
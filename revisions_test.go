@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/outbox"
+	"github.com/jyap808/btcEtfScrape/revision"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestHandleRollback(t *testing.T) {
+	auditPath = filepath.Join(t.TempDir(), "audit.log")
+	revisions, _ = revision.Open("")
+	renderer, _ = message.NewRenderer("")
+	var output bytes.Buffer
+	previousWriter := dryRunWriter
+	dryRun, dryRunWriter = true, &output
+	defer func() {
+		revisions, renderer, dryRun, dryRunWriter = nil, nil, false, previousWriter
+		setResult("FBTC", types.Result{})
+	}()
+
+	good := types.Result{TotalAsset: 1000}
+	bad := types.Result{TotalAsset: 100000}
+	recordRevision("FBTC", revision.Update, "alice", types.Result{}, good, nil)
+	recordRevision("FBTC", revision.Scrape, "", good, bad, &revision.Post{Kind: "flow", TradeDate: "2024-03-01"})
+	setResult("FBTC", bad)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "unknown revision", body: `{"Ticker": "FBTC", "Revision": 99}`, wantStatus: http.StatusNotFound},
+		{name: "latest revision", body: `{"Ticker": "FBTC", "Revision": 2}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "rollback", body: `{"Ticker": "FBTC", "Revision": 1, "Notify": true}`, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleRollback(w, httptest.NewRequest(http.MethodPost, "/rollback", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	if got := currentResult("FBTC"); got != good {
+		t.Errorf("result after rollback = %+v, want %+v", got, good)
+	}
+	list := revisions.List("FBTC")
+	if len(list) != 3 || list[0].Cause != revision.Rollback || list[0].RollbackOf != 1 {
+		t.Errorf("revisions = %+v, want a rollback of 1 on top", list)
+	}
+	if !strings.Contains(output.String(), "kind=correction ticker=FBTC") || !strings.Contains(output.String(), "RETRACTED TOTAL Bitcoin: 100000.0") {
+		t.Errorf("correction not posted, dry run output:\n%s", output.String())
+	}
+}
+
+func TestHandleRollback_Outbox(t *testing.T) {
+	// One channel already posted the bad flow, the other is still waiting
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	queued := `[{"key": "discord/flow/FBTC/2024-03-01/100000", "channel": "discord", "kind": "flow", "ticker": "FBTC", "tradeDate": "2024-03-01", "version": "100000", "status": "sent"},
+		{"key": "x/flow/FBTC/2024-03-01/100000", "channel": "x", "kind": "flow", "ticker": "FBTC", "tradeDate": "2024-03-01", "version": "100000", "status": "pending"}]`
+	if err := os.WriteFile(path, []byte(queued), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	o, err := outbox.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auditPath = filepath.Join(t.TempDir(), "audit.log")
+	revisions, _ = revision.Open("")
+	renderer, _ = message.NewRenderer("")
+	notifications = o
+	defer func() {
+		revisions, renderer, notifications = nil, nil, nil
+		setResult("FBTC", types.Result{})
+	}()
+
+	good := types.Result{TotalAsset: 1000}
+	bad := types.Result{TotalAsset: 100000}
+	recordRevision("FBTC", revision.Update, "alice", types.Result{}, good, nil)
+	recordRevision("FBTC", revision.Scrape, "", good, bad, &revision.Post{Kind: "flow", TradeDate: "2024-03-01", Version: "100000"})
+	setResult("FBTC", bad)
+
+	w := httptest.NewRecorder()
+	handleRollback(w, httptest.NewRequest(http.MethodPost, "/rollback", strings.NewReader(`{"Ticker": "FBTC", "Revision": 1, "Notify": true}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	if canceled := o.Entries(outbox.Canceled); len(canceled) != 1 || canceled[0].Channel != "x" {
+		t.Errorf("canceled = %+v, want the pending x post", canceled)
+	}
+	var corrections []string
+	for _, entry := range o.Entries(outbox.Pending) {
+		if entry.Kind == string(message.Correction) {
+			corrections = append(corrections, entry.Channel)
+		}
+	}
+	if len(corrections) != 1 || corrections[0] != "discord" {
+		t.Errorf("corrections queued on %v, want only discord", corrections)
+	}
+}
+
+func TestDropDailyFlow(t *testing.T) {
+	defer func() { dailyFlows = nil }()
+
+	// Undated flows are dropped by the trade date their post was queued under
+	undated := message.Event{Ticker: "EZBC", AssetDiff: 10}
+	recordDailyFlow(undated, &revision.Post{Kind: "flow", TradeDate: "2024-03-01"})
+	recordDailyFlow(message.Event{Ticker: "IBIT", AssetDiff: 20}, &revision.Post{Kind: "flow", TradeDate: "2024-03-01"})
+
+	dropDailyFlow("EZBC", "2024-03-01")
+	if len(dailyFlows) != 1 || dailyFlows[0].event.Ticker != "IBIT" {
+		t.Errorf("daily flows = %+v, want only IBIT", dailyFlows)
+	}
+}
+
+func TestHandleRollback_Repeated(t *testing.T) {
+	auditPath = filepath.Join(t.TempDir(), "audit.log")
+	revisions, _ = revision.Open("")
+	renderer, _ = message.NewRenderer("")
+	var output bytes.Buffer
+	previousWriter := dryRunWriter
+	dryRun, dryRunWriter = true, &output
+	defer func() {
+		revisions, renderer, dryRun, dryRunWriter = nil, nil, false, previousWriter
+		setResult("FBTC", types.Result{})
+		resultsMu.Lock()
+		delete(rejected, "FBTC")
+		delete(quarantined, "FBTC")
+		resultsMu.Unlock()
+	}()
+
+	good := types.Result{TotalAsset: 1000}
+	bad := types.Result{TotalAsset: 100000}
+	worse := types.Result{TotalAsset: 200000}
+	recordRevision("FBTC", revision.Update, "alice", types.Result{}, good, nil)
+	recordRevision("FBTC", revision.Scrape, "", good, bad, &revision.Post{Kind: "flow", TradeDate: "2024-03-01", Version: "100000"})
+	recordRevision("FBTC", revision.Scrape, "", bad, worse, &revision.Post{Kind: "flow", TradeDate: "2024-03-01", Version: "200000"})
+	setResult("FBTC", worse)
+
+	rollback := func(body string) map[string][]string {
+		w := httptest.NewRecorder()
+		handleRollback(w, httptest.NewRequest(http.MethodPost, "/rollback", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		var response struct {
+			Undone      []revision.Revision
+			Corrections map[string][]string
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Corrections
+	}
+
+	// Both flows of the day are corrected on every channel
+	channels := 1 + len(socialChannels())
+	corrections := rollback(`{"Ticker": "FBTC", "Revision": 1, "Notify": true}`)
+	if got := len(corrections["2024-03-01"]); got != 2*channels {
+		t.Errorf("corrections = %v, want both flows on every channel", corrections)
+	}
+
+	// The result rolled back is held when the fund returns it again
+	if guardScrape("FBTC", good, worse) {
+		t.Error("rolled back result accepted again")
+	}
+
+	// A second rollback to the same revision does not correct them again
+	output.Reset()
+	if corrections := rollback(`{"Ticker": "FBTC", "Revision": 1, "Notify": true}`); len(corrections) != 0 {
+		t.Errorf("second rollback corrections = %v", corrections)
+	}
+	if strings.Contains(output.String(), "kind=correction") {
+		t.Errorf("second rollback posted corrections:\n%s", output.String())
+	}
+}
+
+func TestReplaceResult(t *testing.T) {
+	defer setResult("GBTC", types.Result{})
+
+	setResult("GBTC", types.Result{TotalAsset: 1000})
+	polled := currentResult("GBTC")
+
+	// A rollback lands while the poll runs
+	setResult("GBTC", types.Result{TotalAsset: 900})
+	if replaceResult("GBTC", polled, types.Result{TotalAsset: 1100}) {
+		t.Error("stale poll replaced the rolled back result")
+	}
+	if got := currentResult("GBTC"); got.TotalAsset != 900 {
+		t.Errorf("result = %+v, want the rollback", got)
+	}
+	if !replaceResult("GBTC", currentResult("GBTC"), types.Result{TotalAsset: 1100}) {
+		t.Error("current poll not applied")
+	}
+}
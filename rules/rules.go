@@ -0,0 +1,368 @@
+/*
+Package rules evaluates flow events against user defined alert rules.
+
+Rules are read from a JSON file. Every condition set on a rule must hold for
+the rule to match, and a matching event is routed to the rule's channels.
+*/
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/calendar"
+	"github.com/jyap808/btcEtfScrape/message"
+)
+
+type Rule struct {
+	Name     string   `json:"name"`
+	Channels []string `json:"channels"`
+	// Tickers limits the rule to these funds. Empty matches every fund.
+	Tickers []string `json:"tickers,omitempty"`
+
+	UsdFlowAbove           *float64 `json:"usdFlowAbove,omitempty"`
+	UsdFlowBelow           *float64 `json:"usdFlowBelow,omitempty"`
+	HoldingsChangePctAbove *float64 `json:"holdingsChangePctAbove,omitempty"`
+	HoldingsChangePctBelow *float64 `json:"holdingsChangePctBelow,omitempty"`
+	ConsecutiveInflows     int      `json:"consecutiveInflows,omitempty"`
+	ConsecutiveOutflows    int      `json:"consecutiveOutflows,omitempty"`
+
+	// NoUpdateBy is a New York time such as "10:00". The rule fires on a
+	// trading day when a fund has not updated since the previous day.
+	NoUpdateBy string `json:"noUpdateBy,omitempty"`
+}
+
+type Match struct {
+	Rule   Rule
+	Event  message.Event
+	Reason string
+}
+
+// Load reads and validates a rules file
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	names := map[string]bool{}
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%s: duplicate rule name %q", path, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return rules, nil
+}
+
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("%s: at least one channel is required", r.Name)
+	}
+	if r.NoUpdateBy != "" {
+		if _, err := time.Parse("15:04", r.NoUpdateBy); err != nil {
+			return fmt.Errorf("%s: noUpdateBy %q is not HH:MM", r.Name, r.NoUpdateBy)
+		}
+		if r.hasFlowCondition() {
+			return fmt.Errorf("%s: noUpdateBy can not be combined with flow conditions", r.Name)
+		}
+	} else if !r.hasFlowCondition() {
+		return fmt.Errorf("%s: no condition set", r.Name)
+	}
+	return nil
+}
+
+func (r Rule) hasFlowCondition() bool {
+	return r.UsdFlowAbove != nil || r.UsdFlowBelow != nil ||
+		r.HoldingsChangePctAbove != nil || r.HoldingsChangePctBelow != nil ||
+		r.ConsecutiveInflows > 0 || r.ConsecutiveOutflows > 0
+}
+
+func (r Rule) appliesTo(ticker string) bool {
+	if len(r.Tickers) == 0 {
+		return true
+	}
+	for _, t := range r.Tickers {
+		if t == ticker {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangePct is the holdings change as a percentage of the previous total
+func ChangePct(event message.Event) float64 {
+	previous := event.TotalAsset - event.AssetDiff
+	if previous == 0 {
+		return 0
+	}
+	return event.AssetDiff / previous * 100
+}
+
+// streak counts the trailing flows with the same sign as want
+func streak(history []message.Event, outflow bool) int {
+	count := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if (history[i].AssetDiff < 0) != outflow || history[i].AssetDiff == 0 {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// matchFlow checks the flow conditions of a rule against the ticker history
+// ending with the event. It returns the reasons when every condition holds.
+func (r Rule) matchFlow(event message.Event, history []message.Event) (string, bool) {
+	if r.NoUpdateBy != "" || !r.appliesTo(event.Ticker) {
+		return "", false
+	}
+
+	var reasons []string
+	if r.UsdFlowAbove != nil {
+		if event.FlowDiff <= *r.UsdFlowAbove {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("USD flow $%.0f above $%.0f", event.FlowDiff, *r.UsdFlowAbove))
+	}
+	if r.UsdFlowBelow != nil {
+		if event.FlowDiff >= *r.UsdFlowBelow {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("USD flow $%.0f below $%.0f", event.FlowDiff, *r.UsdFlowBelow))
+	}
+	pct := ChangePct(event)
+	if r.HoldingsChangePctAbove != nil {
+		if pct <= *r.HoldingsChangePctAbove {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("holdings change %.2f%% above %.2f%%", pct, *r.HoldingsChangePctAbove))
+	}
+	if r.HoldingsChangePctBelow != nil {
+		if pct >= *r.HoldingsChangePctBelow {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("holdings change %.2f%% below %.2f%%", pct, *r.HoldingsChangePctBelow))
+	}
+	if r.ConsecutiveInflows > 0 {
+		if n := streak(history, false); n < r.ConsecutiveInflows {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("%d consecutive inflows", streak(history, false)))
+	}
+	if r.ConsecutiveOutflows > 0 {
+		if n := streak(history, true); n < r.ConsecutiveOutflows {
+			return "", false
+		}
+		reasons = append(reasons, fmt.Sprintf("%d consecutive outflows", streak(history, true)))
+	}
+
+	return strings.Join(reasons, ", "), true
+}
+
+// Engine holds the rules and the per ticker state they are evaluated against
+type Engine struct {
+	mu         sync.Mutex
+	rules      []Rule
+	history    map[string][]message.Event
+	lastUpdate map[string]time.Time
+	// fired remembers the New York date a missing update rule last fired for a ticker
+	fired    map[string]string
+	location *time.Location
+
+	// path and modTime track the file for hot reloading
+	path    string
+	modTime time.Time
+}
+
+// maxHistory bounds the flows kept per ticker for streak conditions
+const maxHistory = 30
+
+func NewEngine(rules []Rule) *Engine {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		location = time.FixedZone("EST", -5*60*60)
+	}
+
+	return &Engine{
+		rules:      rules,
+		history:    map[string][]message.Event{},
+		lastUpdate: map[string]time.Time{},
+		fired:      map[string]string{},
+		location:   location,
+	}
+}
+
+// Rules returns the active rules
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.rules
+}
+
+// SetRules swaps the active rules, keeping the event history
+func (e *Engine) SetRules(rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = rules
+}
+
+// Seen records that a fund updated at the given time without a flow, such as an
+// unchanged scrape
+func (e *Engine) Seen(ticker string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastUpdate[ticker] = at
+}
+
+// Evaluate records a flow event seen at the given time and returns the rules it matches
+func (e *Engine) Evaluate(event message.Event, at time.Time) []Match {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastUpdate[event.Ticker] = at
+
+	history := append(e.history[event.Ticker], event)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	e.history[event.Ticker] = history
+
+	var matches []Match
+	for _, rule := range e.rules {
+		if reason, ok := rule.matchFlow(event, history); ok {
+			matches = append(matches, Match{Rule: rule, Event: event, Reason: reason})
+		}
+	}
+
+	return matches
+}
+
+// CheckMissing returns the missing update rules due at now. Each rule fires
+// at most once per ticker per trading day, and never on weekends or market
+// holidays.
+func (e *Engine) CheckMissing(now time.Time, tickers []string) []Match {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	local := now.In(e.location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, e.location)
+	if !calendar.IsTradingDay(today) {
+		return nil
+	}
+
+	var matches []Match
+	for _, rule := range e.rules {
+		if rule.NoUpdateBy == "" {
+			continue
+		}
+		by, _ := time.Parse("15:04", rule.NoUpdateBy)
+		deadline := today.Add(time.Duration(by.Hour())*time.Hour + time.Duration(by.Minute())*time.Minute)
+		if local.Before(deadline) {
+			continue
+		}
+
+		for _, ticker := range tickers {
+			if !rule.appliesTo(ticker) {
+				continue
+			}
+			key := rule.Name + "/" + ticker
+			if e.fired[key] == today.Format("2006-01-02") {
+				continue
+			}
+			if !e.lastUpdate[ticker].Before(today) {
+				continue
+			}
+
+			e.fired[key] = today.Format("2006-01-02")
+			reason := fmt.Sprintf("no update by %s ET", rule.NoUpdateBy)
+			if last := e.lastUpdate[ticker]; !last.IsZero() {
+				reason += fmt.Sprintf(", last update %s", last.In(e.location).Format("01/02/2006 15:04"))
+			}
+			matches = append(matches, Match{Rule: rule, Event: message.Event{Ticker: ticker, Date: today}, Reason: reason})
+		}
+	}
+
+	return matches
+}
+
+// Watch sets the file to reload from when it changes
+func (e *Engine) Watch(path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.path = path
+	if info, err := os.Stat(path); err == nil {
+		e.modTime = info.ModTime()
+	}
+}
+
+// Reload reads the watched file if it changed since the last load. Invalid
+// files return an error and keep the current rules.
+func (e *Engine) Reload() (bool, error) {
+	e.mu.Lock()
+	path, modTime := e.path, e.modTime
+	e.mu.Unlock()
+
+	if path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(modTime) {
+		return false, nil
+	}
+
+	rules, err := Load(path)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Remember the bad version so it is only reported once
+	e.modTime = info.ModTime()
+	if err != nil {
+		return false, err
+	}
+	e.rules = rules
+
+	return true, nil
+}
+
+// Replay evaluates historical flow events in order, for testing rules offline
+func Replay(rules []Rule, events []message.Event) []Match {
+	engine := NewEngine(rules)
+
+	var matches []Match
+	for _, event := range events {
+		matches = append(matches, engine.Evaluate(event, event.Date)...)
+	}
+
+	return matches
+}
+
+// Float returns a pointer for optional rule thresholds
+func Float(v float64) *float64 {
+	return &v
+}
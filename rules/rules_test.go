@@ -0,0 +1,119 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/message"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 2, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestReplay(t *testing.T) {
+	rules, err := Load("testdata/rules.json")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	events := []message.Event{
+		{Ticker: "IBIT", Date: day(12), AssetDiff: 5000, TotalAsset: 105000, FlowDiff: 250000000},
+		{Ticker: "GBTC", Date: day(12), AssetDiff: -1000, TotalAsset: 449000, FlowDiff: -50000000},
+		{Ticker: "GBTC", Date: day(13), AssetDiff: -1000, TotalAsset: 448000, FlowDiff: -50000000},
+		{Ticker: "FBTC", Date: day(13), AssetDiff: -3000, TotalAsset: 97000, FlowDiff: -150000000},
+		{Ticker: "GBTC", Date: day(14), AssetDiff: -1000, TotalAsset: 447000, FlowDiff: -50000000},
+		{Ticker: "GBTC", Date: day(15), AssetDiff: 500, TotalAsset: 447500, FlowDiff: 25000000},
+	}
+
+	var got []string
+	for _, match := range Replay(rules, events) {
+		got = append(got, match.Rule.Name+" "+match.Event.Ticker+" "+match.Event.Date.Format("01/02"))
+	}
+	want := []string{
+		"big-inflow IBIT 02/12",
+		"holdings-drop FBTC 02/13",
+		"gbtc-outflow-streak GBTC 02/14",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Replay() got = %v, want %v", got, want)
+	}
+}
+
+func TestEngine_CheckMissing(t *testing.T) {
+	rules, err := Load("testdata/rules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(rules)
+	newYork := engine.location
+
+	// Friday before and after the deadline
+	engine.Seen("IBIT", time.Date(2024, 2, 15, 5, 0, 0, 0, newYork))
+	if matches := engine.CheckMissing(time.Date(2024, 2, 16, 9, 59, 0, 0, newYork), []string{"IBIT", "FBTC"}); len(matches) != 0 {
+		t.Errorf("CheckMissing() before deadline got %d matches", len(matches))
+	}
+	matches := engine.CheckMissing(time.Date(2024, 2, 16, 10, 1, 0, 0, newYork), []string{"IBIT", "FBTC"})
+	if len(matches) != 1 || matches[0].Event.Ticker != "IBIT" {
+		t.Fatalf("CheckMissing() got = %+v, want IBIT only", matches)
+	}
+
+	// Fires once per day
+	if matches := engine.CheckMissing(time.Date(2024, 2, 16, 11, 0, 0, 0, newYork), []string{"IBIT"}); len(matches) != 0 {
+		t.Errorf("CheckMissing() fired again the same day")
+	}
+
+	// Not on weekends
+	if matches := engine.CheckMissing(time.Date(2024, 2, 17, 11, 0, 0, 0, newYork), []string{"IBIT"}); len(matches) != 0 {
+		t.Errorf("CheckMissing() fired on a Saturday")
+	}
+
+	// Not on market holidays
+	if matches := engine.CheckMissing(time.Date(2024, 2, 19, 11, 0, 0, 0, newYork), []string{"IBIT"}); len(matches) != 0 {
+		t.Errorf("CheckMissing() fired on Presidents' Day")
+	}
+
+	// Not once updated
+	engine.Seen("IBIT", time.Date(2024, 2, 20, 6, 0, 0, 0, newYork))
+	if matches := engine.CheckMissing(time.Date(2024, 2, 20, 11, 0, 0, 0, newYork), []string{"IBIT"}); len(matches) != 0 {
+		t.Errorf("CheckMissing() fired after an update")
+	}
+}
+
+func TestEngine_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "a", "channels": ["discord"], "usdFlowAbove": 1}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, _ := Load(path)
+	engine := NewEngine(rules)
+	engine.Watch(path)
+
+	if reloaded, err := engine.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() unchanged file = %v, %v", reloaded, err)
+	}
+
+	// Invalid files keep the current rules
+	later := time.Now().Add(time.Second)
+	os.WriteFile(path, []byte(`[{"name": "b", "channels": ["discord"]}]`), 0644)
+	os.Chtimes(path, later, later)
+	if _, err := engine.Reload(); err == nil {
+		t.Error("Reload() accepted a rule without conditions")
+	}
+	if got := engine.Rules(); len(got) != 1 || got[0].Name != "a" {
+		t.Errorf("Rules() after bad reload = %+v", got)
+	}
+
+	later = later.Add(time.Second)
+	os.WriteFile(path, []byte(`[{"name": "c", "channels": ["x"], "usdFlowBelow": -1}]`), 0644)
+	os.Chtimes(path, later, later)
+	if reloaded, err := engine.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload() = %v, %v", reloaded, err)
+	}
+	if got := engine.Rules(); len(got) != 1 || got[0].Name != "c" {
+		t.Errorf("Rules() after reload = %+v", got)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/message"
+)
+
+// loadConfig reads the settings file and checks every enabled fund has its
+// sources
+func loadConfig(path string) (*config.Config, error) {
+	c, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ticker := range c.Tickers(true) {
+		if err := checkSources(c, ticker); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// applyDiscordFlags replaces the configured Discord settings with any given on
+// the command line
+func applyDiscordFlags(c *config.Config) {
+	if webhookURL != "" {
+		c.Discord.WebhookURL = webhookURL
+	}
+	if avatarUsername != "" {
+		c.Discord.AvatarUsername = avatarUsername
+	}
+	if avatarURL != "" {
+		c.Discord.AvatarURL = avatarURL
+	}
+}
+
+// fundDetails returns the details of every enabled fund
+func fundDetails(c *config.Config) map[string]tickerDetail {
+	details := map[string]tickerDetail{}
+	for _, ticker := range c.Tickers(true) {
+		fund := c.Funds[ticker]
+		details[ticker] = tickerDetail{
+			Description: fund.Description,
+			Note:        fund.Note,
+			Delayed:     fund.Delayed,
+			URL:         fund.URL,
+		}
+	}
+	return details
+}
+
+func conf() *config.Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	return cfg
+}
+
+// details returns the enabled funds. The map is replaced, never changed, on reload.
+func details() map[string]tickerDetail {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	return tickerDetails
+}
+
+func templates() *message.Renderer {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	return renderer
+}
+
+// applySettings swaps in a new config and templates together
+func applySettings(c *config.Config, r *message.Renderer) {
+	applyDiscordFlags(c)
+	details := fundDetails(c)
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	cfg, tickerDetails, renderer = c, details, r
+}
+
+// Serializes reloads
+var reloadMu sync.Mutex
+
+// reloadConfig reads the config file and templates again and applies them.
+// Holdings, overrides and revisions are kept. A config that fails to load or
+// validate leaves the current one in place.
+func reloadConfig() (started, stopped []string, err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	c, err := loadConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := message.NewRenderer(templateDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous := conf()
+	applySettings(c, r)
+	if notifications != nil {
+		registerDiscord(notifications, c)
+	}
+	if c.Listen != previous.Listen {
+		log.Printf("Warning: listen address change to %s needs a restart", c.Listen)
+	}
+
+	started, stopped = syncFunds()
+	log.Printf("Reloaded config, started %v, stopped %v", started, stopped)
+	return started, stopped, nil
+}
+
+var (
+	// Cancels the collector goroutine of each running fund
+	fundCancels = map[string]context.CancelFunc{}
+	fundsMu     sync.Mutex
+)
+
+// syncFunds starts a collector for every enabled fund that is not running
+// and stops the ones no longer enabled
+func syncFunds() (started, stopped []string) {
+	fundsMu.Lock()
+	defer fundsMu.Unlock()
+
+	enabled := details()
+	for ticker, cancel := range fundCancels {
+		if _, ok := enabled[ticker]; !ok {
+			cancel()
+			delete(fundCancels, ticker)
+			stopped = append(stopped, ticker)
+		}
+	}
+	for ticker := range enabled {
+		if _, running := fundCancels[ticker]; running {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		fundCancels[ticker] = cancel
+		go handleFund(ctx, ticker)
+		started = append(started, ticker)
+	}
+	sort.Strings(started)
+	sort.Strings(stopped)
+
+	return started, stopped
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// handleReload reloads the config file, like SIGHUP
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	started, stopped, err := reloadConfig()
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	audit(r, "reload", "", nil, map[string][]string{"started": started, "stopped": stopped})
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "reload successful",
+		"started": started,
+		"stopped": stopped,
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/funds"
+	"github.com/jyap808/btcEtfScrape/funds/scrape"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestHandleReload(t *testing.T) {
+	dir := t.TempDir()
+	auditPath = filepath.Join(dir, "audit.log")
+	configPath = filepath.Join(dir, "config.yaml")
+
+	savedCollectors := collectors
+	collectors = map[string][]funds.Source{}
+	for ticker, sources := range savedCollectors {
+		collectors[ticker] = []funds.Source{{Name: sources[0].Name, Collect: func() (types.Result, *scrape.Diagnostics, error) { return types.Result{}, nil, nil }}}
+		// Pending overrides from other tests would be applied by the collectors
+		takeOverride(ticker)
+	}
+	defer func() {
+		fundsMu.Lock()
+		for ticker, cancel := range fundCancels {
+			cancel()
+			delete(fundCancels, ticker)
+		}
+		fundsMu.Unlock()
+		collectors, configPath = savedCollectors, ""
+		applySettings(config.Default(), nil)
+		setResult("IBIT", types.Result{})
+	}()
+
+	if started, _ := syncFunds(); len(started) != len(savedCollectors) {
+		t.Fatalf("started %v, want every fund", started)
+	}
+	setResult("IBIT", types.Result{TotalAsset: 1234})
+
+	reload := func(yaml string) *httptest.ResponseRecorder {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		handleReload(w, httptest.NewRequest(http.MethodPost, "/reload", nil))
+		return w
+	}
+
+	w := reload("minBitcoinDiff: 3\nfunds:\n  IBIT:\n    note: new note\n  HODL:\n    enabled: false\n")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"stopped":["HODL"]`) {
+		t.Fatalf("reload = %d %s, want HODL stopped", w.Code, w.Body)
+	}
+	if details()["IBIT"].Note != "new note" || conf().MinBitcoinDiff != 3 {
+		t.Errorf("config not applied: note %q, min diff %v", details()["IBIT"].Note, conf().MinBitcoinDiff)
+	}
+	if _, ok := details()["HODL"]; ok {
+		t.Error("HODL still enabled")
+	}
+	if got := currentResult("IBIT"); got.TotalAsset != 1234 {
+		t.Errorf("IBIT result after reload = %+v, want it kept", got)
+	}
+
+	w = reload("summaryHour: 99\n")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid reload = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if conf().MinBitcoinDiff != 3 {
+		t.Error("invalid config replaced the current one")
+	}
+
+	w = reload("funds:\n  IBIT:\n    note: new note\n")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"started":["HODL"]`) {
+		t.Fatalf("reload = %d %s, want HODL started", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/calendar"
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/funds"
+	"github.com/jyap808/btcEtfScrape/message"
+)
+
+const (
+	statusDisagree = "sources disagree"
+
+	// Rule name of the alert sent when a fund's sources disagree
+	disagreementRule = "source-disagreement"
+)
+
+// sourcesFor returns the sources a fund is configured to use in order, every
+// registered one when none are configured
+func sourcesFor(c *config.Config, ticker string) []funds.Source {
+	names := c.Funds[ticker].Sources
+	if len(names) == 0 {
+		return collectors[ticker]
+	}
+
+	var sources []funds.Source
+	for _, name := range names {
+		for _, source := range collectors[ticker] {
+			if source.Name == name {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
+
+// checkSources reports configured sources that have no collector
+func checkSources(c *config.Config, ticker string) error {
+	if len(collectors[ticker]) == 0 {
+		return fmt.Errorf("funds.%s: no collector for this ticker", ticker)
+	}
+
+	for _, name := range c.Funds[ticker].Sources {
+		known := false
+		var names []string
+		for _, source := range collectors[ticker] {
+			known = known || source.Name == name
+			names = append(names, source.Name)
+		}
+		if !known {
+			return fmt.Errorf("funds.%s.sources: unknown source %q, want one of %v", ticker, name, names)
+		}
+	}
+	return nil
+}
+
+// combineSources collects from the fund's sources using its policy
+func combineSources(c *config.Config, ticker string) funds.Outcome {
+	return funds.Combine(funds.Policy(c.FundPolicy(ticker)), c.FundSourceTolerance(ticker), sourcesFor(c, ticker))
+}
+
+// collectFund collects the fund's combined holdings. Source errors are logged.
+// A disagreement is logged and alerted on the ops channels once per trade date
+// so a broken source is caught before it posts.
+func collectFund(ticker string) funds.Outcome {
+	outcome := combineSources(conf(), ticker)
+	if err := outcome.Err(); err != nil {
+		log.Printf("%s collect error: %v", ticker, err)
+	}
+	for source, diag := range outcome.Diagnostics {
+		checkDrift(ticker, source, diag)
+	}
+	if outcome.Disagreement == "" {
+		return outcome
+	}
+
+	log.Printf("%s %s, using %q: %+v", ticker, outcome.Disagreement, outcome.Source, outcome.Result)
+
+	var date time.Time
+	for _, result := range outcome.Results {
+		if result.Date.After(date) {
+			date = result.Date
+		}
+	}
+	// Undated sources are alerted once per day
+	day := date
+	if day.IsZero() {
+		day = calendar.Day(time.Now())
+	}
+	if alertOnce(disagreementRule, ticker, day.Format("2006-01-02")) {
+		notifyOps(disagreementRule, message.Event{Ticker: ticker, Date: date}, outcome.Disagreement)
+	}
+
+	return outcome
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/calendar"
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/message"
+)
+
+const (
+	// Rule names of the alerts sent when a fund misses its expected update
+	// and when its holdings are too many trading days old
+	missingUpdateRule = "missing-update"
+	staleSourceRule   = "stale-source"
+)
+
+// Undated sources are measured from startup until they first return holdings
+var started = time.Now()
+
+// staleness is how far a fund is behind its publication schedule
+type staleness struct {
+	Ticker string `json:"ticker"`
+	// Expected is the as-of date the fund should have published by now, due
+	// at the close plus its publication lag
+	Expected time.Time `json:"expected"`
+	Due      time.Time `json:"due"`
+	// AsOf is the date of the latest holdings returned, zero for undated sources
+	AsOf        time.Time `json:"asOf"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	// LastChange is when the source last returned different holdings
+	LastChange time.Time `json:"lastChange"`
+	// TradingDaysBehind the expected update and Age of the holdings in trading days
+	TradingDaysBehind int    `json:"tradingDaysBehind"`
+	Age               int    `json:"age"`
+	Missing           bool   `json:"missing"`
+	Stale             bool   `json:"stale"`
+	Reason            string `json:"reason,omitempty"`
+}
+
+// expectedUpdate returns the latest as-of date a fund should have published
+// at now and when it was due
+func expectedUpdate(now time.Time, lag time.Duration, delayed bool) (asOf, due time.Time) {
+	day := calendar.Latest(calendar.Day(now))
+	for calendar.ClosingTime(day).Add(lag).After(now) {
+		day = calendar.Previous(day)
+	}
+	due = calendar.ClosingTime(day).Add(lag)
+
+	// Delayed funds publish the holdings of the trading day before
+	if delayed {
+		day = calendar.Previous(day)
+	}
+	return day, due
+}
+
+// fundStaleness compares what a fund last returned with its schedule. Dated
+// sources are measured by the as-of date of their holdings, undated ones by
+// when they last returned any, changed or not, as a day without flows
+// republishes the same holdings.
+func fundStaleness(c *config.Config, ticker string, status fundStatus, now time.Time) staleness {
+	expected, due := expectedUpdate(now, c.FundPublicationLag(ticker), c.Funds[ticker].Delayed)
+	s := staleness{
+		Ticker:      ticker,
+		Expected:    expected,
+		Due:         due,
+		AsOf:        status.LastAsOf,
+		LastSuccess: status.LastSuccess,
+		LastChange:  status.LastChange,
+	}
+
+	today := calendar.Latest(calendar.Day(now))
+	if !s.AsOf.IsZero() {
+		s.TradingDaysBehind = calendar.Between(s.AsOf, expected)
+		s.Age = calendar.Between(s.AsOf, today)
+		if s.TradingDaysBehind > 0 {
+			s.Reason = fmt.Sprintf("holdings as of %s, expected %s by %s", s.AsOf.Format("2006-01-02"),
+				expected.Format("2006-01-02"), due.In(calendar.Location()).Format("01/02 15:04 MST"))
+		}
+	} else {
+		since := s.LastSuccess
+		if since.IsZero() {
+			since = started
+		}
+		if since.Before(due) {
+			s.TradingDaysBehind = calendar.Between(calendar.Day(since), expected) + 1
+			s.Age = calendar.Between(calendar.Day(since), today)
+			s.Reason = fmt.Sprintf("no holdings returned since %s, expected by %s", since.In(calendar.Location()).Format("01/02 15:04 MST"),
+				due.In(calendar.Location()).Format("01/02 15:04 MST"))
+		}
+	}
+
+	s.Missing = s.TradingDaysBehind > 0
+	s.Stale = s.Age > c.StaleTradingDays
+	return s
+}
+
+// stalenessAll returns every enabled fund's staleness sorted by ticker
+func stalenessAll(now time.Time) []staleness {
+	c := conf()
+
+	resultsMu.Lock()
+	statuses := map[string]fundStatus{}
+	for ticker := range details() {
+		statuses[ticker] = tickerStatus[ticker]
+	}
+	resultsMu.Unlock()
+
+	all := make([]staleness, 0, len(statuses))
+	for ticker, status := range statuses {
+		all = append(all, fundStaleness(c, ticker, status, now))
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Ticker < all[j].Ticker
+	})
+	return all
+}
+
+var (
+	// The expected date, day or other value each alert last fired for, keyed
+	// by rule and ticker
+	staleAlerted   = map[string]string{}
+	staleAlertedMu sync.Mutex
+)
+
+// alertOnce reports whether an alert has not yet fired for this rule and
+// ticker with this value, usually a day
+func alertOnce(rule, ticker, day string) bool {
+	staleAlertedMu.Lock()
+	defer staleAlertedMu.Unlock()
+
+	key := rule + "/" + ticker
+	if staleAlerted[key] == day {
+		return false
+	}
+	staleAlerted[key] = day
+	return true
+}
+
+// checkStaleness alerts the ops channels once for each expected update a
+// fund misses, and once a day while its holdings are too old
+func checkStaleness(now time.Time) {
+	for _, s := range stalenessAll(now) {
+		description := details()[s.Ticker].Description
+		if s.Missing && alertOnce(missingUpdateRule, s.Ticker, s.Expected.Format("2006-01-02")) {
+			log.Printf("%s missed its expected update: %s", s.Ticker, s.Reason)
+			notifyOps(missingUpdateRule, message.Event{Ticker: s.Ticker, Description: description, Date: s.Expected}, s.Reason)
+		}
+
+		today := calendar.Day(now)
+		if s.Stale && alertOnce(staleSourceRule, s.Ticker, today.Format("2006-01-02")) {
+			reason := fmt.Sprintf("holdings are %d trading days old, more than %d: %s", s.Age, conf().StaleTradingDays, s.Reason)
+			log.Printf("%s source is stale: %s", s.Ticker, reason)
+			notifyOps(staleSourceRule, message.Event{Ticker: s.Ticker, Description: description, Date: today}, reason)
+		}
+	}
+}
+
+// handleStaleness lists how far each fund is behind its publication schedule
+func handleStaleness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, stalenessAll(time.Now()))
+}
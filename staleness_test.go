@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/calendar"
+	"github.com/jyap808/btcEtfScrape/config"
+	"github.com/jyap808/btcEtfScrape/rules"
+	"github.com/jyap808/btcEtfScrape/types"
+)
+
+func TestExpectedUpdate(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, time.July, day, hour, 0, 0, 0, calendar.Location())
+	}
+	date := func(day int) string {
+		return time.Date(2024, time.July, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		lag     time.Duration
+		delayed bool
+		want    string
+	}{
+		{name: "before the lag passes", now: at(3, 20), lag: 12 * time.Hour, want: date(2)},
+		{name: "after the lag passes", now: at(3, 20), lag: 4 * time.Hour, want: date(3)},
+		{name: "over a holiday", now: at(5, 9), lag: 12 * time.Hour, want: date(3)},
+		{name: "friday night", now: at(6, 3), lag: 12 * time.Hour, want: date(3)},
+		{name: "over a weekend", now: at(8, 5), lag: 12 * time.Hour, want: date(5)},
+		{name: "delayed fund", now: at(8, 5), lag: 12 * time.Hour, delayed: true, want: date(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asOf, due := expectedUpdate(tt.now, tt.lag, tt.delayed)
+			if asOf.Format("2006-01-02") != tt.want {
+				t.Errorf("expectedUpdate() = %s, want %s", asOf.Format("2006-01-02"), tt.want)
+			}
+			if due.After(tt.now) {
+				t.Errorf("due %s after now %s", due, tt.now)
+			}
+		})
+	}
+}
+
+func TestFundStaleness(t *testing.T) {
+	c := config.Default()
+	c.PublicationLag = config.Duration(12 * time.Hour)
+	c.StaleTradingDays = 2
+	// Wednesday 10 July, the 9 July holdings are due
+	now := time.Date(2024, time.July, 10, 9, 0, 0, 0, calendar.Location())
+	asOf := func(day int) time.Time {
+		return time.Date(2024, time.July, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name        string
+		status      fundStatus
+		wantBehind  int
+		wantMissing bool
+		wantStale   bool
+	}{
+		{name: "up to date", status: fundStatus{LastAsOf: asOf(9)}},
+		{name: "one update missed", status: fundStatus{LastAsOf: asOf(8)}, wantBehind: 1, wantMissing: true},
+		{name: "stale over the weekend", status: fundStatus{LastAsOf: asOf(5)}, wantBehind: 2, wantMissing: true, wantStale: true},
+		{name: "undated and recent", status: fundStatus{LastSuccess: now.Add(-time.Hour), LastChange: now.Add(-time.Hour)}},
+		{name: "undated since before due", status: fundStatus{LastSuccess: now.Add(-48 * time.Hour), LastChange: now.Add(-48 * time.Hour)}, wantBehind: 2, wantMissing: true},
+		{name: "undated and unchanged", status: fundStatus{LastSuccess: now.Add(-time.Hour), LastChange: now.Add(-48 * time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fundStaleness(c, "EZBC", tt.status, now)
+			if s.TradingDaysBehind != tt.wantBehind || s.Missing != tt.wantMissing || s.Stale != tt.wantStale {
+				t.Errorf("fundStaleness() = %+v, want %d behind, missing %v, stale %v", s, tt.wantBehind, tt.wantMissing, tt.wantStale)
+			}
+			if tt.wantMissing && s.Reason == "" {
+				t.Error("missing update without a reason")
+			}
+		})
+	}
+}
+
+func TestRecordScrape_LastChange(t *testing.T) {
+	defer func() {
+		resultsMu.Lock()
+		delete(tickerStatus, "EZBC")
+		resultsMu.Unlock()
+	}()
+	lastChange := func() time.Time {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		return tickerStatus["EZBC"].LastChange
+	}
+
+	first := time.Date(2024, time.July, 10, 9, 0, 0, 0, calendar.Location())
+	recordScrape("EZBC", first, types.Result{TotalAsset: 1000}, false, nil)
+	if got := lastChange(); !got.Equal(first) {
+		t.Fatalf("LastChange = %s, want the first holdings at %s", got, first)
+	}
+
+	recordScrape("EZBC", first.Add(time.Hour), types.Result{TotalAsset: 1000}, false, nil)
+	recordScrape("EZBC", first.Add(2*time.Hour), types.Result{}, false, errors.New("timeout"))
+	if got := lastChange(); !got.Equal(first) {
+		t.Errorf("LastChange moved to %s without a change", got)
+	}
+
+	recordScrape("EZBC", first.Add(3*time.Hour), types.Result{TotalAsset: 1010}, false, nil)
+	if got := lastChange(); !got.Equal(first.Add(3 * time.Hour)) {
+		t.Errorf("LastChange = %s, want %s", got, first.Add(3*time.Hour))
+	}
+}
+
+func TestRecordScrape_Seen(t *testing.T) {
+	saved := rulesEngine
+	defer func() {
+		rulesEngine = saved
+		resultsMu.Lock()
+		delete(tickerStatus, "EZBC")
+		delete(tickerStatus, "BRRR")
+		resultsMu.Unlock()
+	}()
+	rulesEngine = rules.NewEngine([]rules.Rule{{Name: "late", Channels: []string{"discord"}, NoUpdateBy: "10:00"}})
+
+	// Tuesday 20 February, EZBC returns the holdings it had the day before
+	// ahead of the deadline, BRRR fails
+	scraped := time.Date(2024, time.February, 20, 9, 0, 0, 0, calendar.Location())
+	recordScrape("EZBC", scraped, types.Result{TotalAsset: 1000}, false, nil)
+	recordScrape("EZBC", scraped.Add(time.Minute), types.Result{TotalAsset: 1000}, false, nil)
+	recordScrape("BRRR", scraped, types.Result{}, false, errors.New("timeout"))
+
+	missing := map[string]bool{}
+	for _, match := range rulesEngine.CheckMissing(scraped.Add(90*time.Minute), []string{"EZBC", "BRRR"}) {
+		missing[match.Event.Ticker] = true
+	}
+	if missing["EZBC"] {
+		t.Error("unchanged holdings before the deadline alerted as missing")
+	}
+	if !missing["BRRR"] {
+		t.Error("failed scrape not alerted as missing")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jyap808/btcEtfScrape/message"
+	"github.com/jyap808/btcEtfScrape/revision"
+)
+
+// dailyFlow is a flow waiting for the daily summary and the trade date its
+// post was queued under
+type dailyFlow struct {
+	tradeDate string
+	event     message.Event
+}
+
+var (
+	dailyFlows   []dailyFlow
+	dailyFlowsMu sync.Mutex
+)
+
+// recordDailyFlow adds a flow to the next daily summary
+func recordDailyFlow(event message.Event, post *revision.Post) {
+	dailyFlowsMu.Lock()
+	defer dailyFlowsMu.Unlock()
+
+	dailyFlows = append(dailyFlows, dailyFlow{tradeDate: post.TradeDate, event: event})
+}
+
+// dropDailyFlow removes a rolled back flow from the next daily summary
+func dropDailyFlow(ticker, tradeDate string) {
+	dailyFlowsMu.Lock()
+	defer dailyFlowsMu.Unlock()
+
+	kept := dailyFlows[:0]
+	for _, flow := range dailyFlows {
+		if flow.event.Ticker == ticker && flow.tradeDate == tradeDate {
+			continue
+		}
+		kept = append(kept, flow)
+	}
+	dailyFlows = kept
+}
+
+// runDailySummary posts the flows seen since the last summary once a day
+// when the daily summary is enabled
+func runDailySummary() {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		log.Println("Daily summary disabled:", err)
+		return
+	}
+
+	for {
+		now := time.Now().In(newYork)
+		next := time.Date(now.Year(), now.Month(), now.Day(), conf().SummaryHour, 0, 0, 0, newYork)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+
+		dailyFlowsMu.Lock()
+		flows := dailyFlows
+		dailyFlows = nil
+		dailyFlowsMu.Unlock()
+
+		// The flows are still cleared while disabled so enabling it by a
+		// reload does not post a backlog
+		if len(flows) == 0 || !conf().DailySummary {
+			continue
+		}
+
+		summary := message.Event{Date: next}
+		for _, flow := range flows {
+			summary.Flows = append(summary.Flows, flow.event)
+			summary.AssetDiff += flow.event.AssetDiff
+			summary.FlowDiff += flow.event.FlowDiff
+		}
+
+		notify(message.Summary, summary)
+	}
+}
@@ -1,8 +1,73 @@
 package types
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 type Result struct {
 	TotalAsset float64
 	Date       time.Time
+
+	// Optional fund level data, zero when the source does not publish it
+	SharesOutstanding float64
+	NAV               float64
+	BitcoinPerShare   float64
+
+	// Reconciliation of TotalAsset with the holdings implied by the shares
+	// outstanding, nil until it is checked
+	Reconciliation *Reconciliation `json:",omitempty"`
+}
+
+// Reconciliation statuses
+const (
+	Reconciled   = "reconciled"
+	Mismatched   = "mismatched"
+	Unreconciled = "unreconciled"
+)
+
+// Reconciliation compares scraped holdings with shares outstanding times
+// bitcoin per share
+type Reconciliation struct {
+	Status  string  `json:"status"`
+	Implied float64 `json:"implied,omitempty"`
+	DiffPct float64 `json:"diffPct,omitempty"`
+}
+
+// NavFlow is the change in shares outstanding valued at the current NAV per
+// share, the basis issuers use for creations and redemptions. It reports
+// false when either result lacks the data.
+func (r Result) NavFlow(previous Result) (float64, bool) {
+	if r.SharesOutstanding == 0 || previous.SharesOutstanding == 0 || r.NAV == 0 {
+		return 0, false
+	}
+	return (r.SharesOutstanding - previous.SharesOutstanding) * r.NAV, true
+}
+
+// ImpliedHoldings is the bitcoin held according to the shares outstanding
+// and bitcoin per share. It reports false when the result lacks either.
+func (r Result) ImpliedHoldings() (float64, bool) {
+	if r.SharesOutstanding == 0 || r.BitcoinPerShare == 0 {
+		return 0, false
+	}
+	return r.SharesOutstanding * r.BitcoinPerShare, true
+}
+
+// Reconcile compares TotalAsset with the implied holdings. They match when
+// they differ by no more than tolerancePct percent of the implied holdings.
+func (r Result) Reconcile(tolerancePct float64) Reconciliation {
+	implied, ok := r.ImpliedHoldings()
+	if !ok || r.TotalAsset == 0 {
+		return Reconciliation{Status: Unreconciled}
+	}
+
+	reconciliation := Reconciliation{
+		Status:  Reconciled,
+		Implied: implied,
+		DiffPct: (r.TotalAsset - implied) / implied * 100,
+	}
+	if math.Abs(reconciliation.DiffPct) > tolerancePct {
+		reconciliation.Status = Mismatched
+	}
+	return reconciliation
 }
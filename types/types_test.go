@@ -0,0 +1,31 @@
+package types
+
+import "testing"
+
+func TestResult_NavFlow(t *testing.T) {
+	previous := Result{TotalAsset: 1000, SharesOutstanding: 1000000, NAV: 30}
+
+	tests := []struct {
+		name     string
+		result   Result
+		previous Result
+		want     float64
+		wantOK   bool
+	}{
+		{name: "creations", result: Result{SharesOutstanding: 1100000, NAV: 30}, previous: previous, want: 3000000, wantOK: true},
+		{name: "redemptions", result: Result{SharesOutstanding: 950000, NAV: 30}, previous: previous, want: -1500000, wantOK: true},
+		{name: "changed nav values at the new nav", result: Result{SharesOutstanding: 1100000, NAV: 32.5}, previous: previous, want: 3250000, wantOK: true},
+		{name: "nav change alone", result: Result{SharesOutstanding: 1000000, NAV: 32.5}, previous: previous, want: 0, wantOK: true},
+		{name: "missing nav", result: Result{SharesOutstanding: 1100000}, previous: previous},
+		{name: "missing shares", result: Result{NAV: 30}, previous: previous},
+		{name: "previous without shares", result: Result{SharesOutstanding: 1100000, NAV: 30}, previous: Result{TotalAsset: 1000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.result.NavFlow(tt.previous)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("NavFlow() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}